@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+type BotStats struct {
+	Sent      int
+	Accepted  int
+	Rejected  int
+	Snapshots int
+	Deltas    int
+}
+
+// Bot is a headless client that plays random moves and attacks, used to
+// put load on a NetServer.
+type Bot struct {
+	id   string
+	rate time.Duration
+	rnd  *rand.Rand
+
+	mu      sync.Mutex
+	players map[string]PlayerState
+	stats   BotStats
+}
+
+func NewBot(id string, rate time.Duration, seed int64) *Bot {
+	return &Bot{
+		id:      id,
+		rate:    rate,
+		rnd:     rand.New(rand.NewSource(seed)),
+		players: make(map[string]PlayerState),
+	}
+}
+
+// Run connects to addr and plays until ctx is done or the connection
+// breaks. It returns what the bot saw.
+func (b *Bot) Run(ctx context.Context, addr string) (BotStats, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return BotStats{}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	readErr := make(chan error, 1)
+	go func() { readErr <- b.readLoop(conn) }()
+
+	w := bufio.NewWriter(conn)
+	if err := b.send(w, clientMessage{Type: msgHello, Player: b.id}); err != nil {
+		return b.Stats(), err
+	}
+
+	ticker := time.NewTicker(b.rate)
+	defer ticker.Stop()
+	var seq uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return b.Stats(), nil
+		case err := <-readErr:
+			if ctx.Err() != nil {
+				return b.Stats(), nil
+			}
+			return b.Stats(), err
+		case <-ticker.C:
+			seq++
+			msg := b.nextAction()
+			msg.Seq = seq
+			if err := b.send(w, msg); err != nil {
+				if ctx.Err() != nil {
+					return b.Stats(), nil
+				}
+				return b.Stats(), err
+			}
+		}
+	}
+}
+
+func (b *Bot) Stats() BotStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+func (b *Bot) send(w *bufio.Writer, msg clientMessage) error {
+	if err := writeFrame(w, msg); err != nil {
+		return err
+	}
+	if msg.Type != msgHello {
+		b.mu.Lock()
+		b.stats.Sent++
+		b.mu.Unlock()
+	}
+	return w.Flush()
+}
+
+// nextAction attacks a random known player one time in four and moves
+// in a random direction otherwise.
+func (b *Bot) nextAction() clientMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rnd.Intn(4) == 0 {
+		var targets []string
+		for id := range b.players {
+			if id != b.id {
+				targets = append(targets, id)
+			}
+		}
+		if len(targets) > 0 {
+			sort.Strings(targets)
+			return clientMessage{Type: msgAttack, Target: targets[b.rnd.Intn(len(targets))]}
+		}
+	}
+	directions := []string{"up", "down", "left", "right"}
+	return clientMessage{Type: msgMove, Direction: directions[b.rnd.Intn(len(directions))]}
+}
+
+func (b *Bot) readLoop(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	for {
+		var msg serverMessage
+		if err := readFrame(r, &msg); err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		switch msg.Type {
+		case msgSnapshot:
+			b.stats.Snapshots++
+			b.players = make(map[string]PlayerState, len(msg.Players))
+			for _, p := range msg.Players {
+				b.players[p.ID] = p
+			}
+		case msgDelta:
+			b.stats.Deltas++
+			for _, p := range msg.Players {
+				b.players[p.ID] = p
+			}
+			for _, id := range msg.Removed {
+				delete(b.players, id)
+			}
+		case msgResult:
+			if msg.Status == StatusAccepted.String() {
+				b.stats.Accepted++
+			} else {
+				b.stats.Rejected++
+			}
+		case msgError:
+			b.stats.Rejected++
+		}
+		b.mu.Unlock()
+	}
+}
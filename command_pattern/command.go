@@ -1,5 +1,10 @@
 package main
 
 type Command interface {
-	Execute()
+	Execute() error
+}
+
+type UndoableCommand interface {
+	Command
+	Undo()
 }
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type LogFormat int
+
+const (
+	FormatJSON LogFormat = iota
+	FormatBinary
+)
+
+// binaryVersion prefixes every binary record. JSON records always start
+// with '{', so the first byte of a log tells the two formats apart.
+const binaryVersion byte = 1
+
+// maxRecordSize bounds a binary record body so a corrupt length prefix
+// cannot make the decoder allocate arbitrary amounts of memory.
+const maxRecordSize = 1 << 20
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported command log format")
+	ErrCorruptRecord     = errors.New("corrupt command log record")
+)
+
+type CommandEncoder interface {
+	Encode(c Command) error
+}
+
+type CommandDecoder interface {
+	// Decode returns io.EOF once the log is exhausted.
+	Decode() (Command, error)
+}
+
+func NewCommandEncoder(w io.Writer, format LogFormat, registry *CommandRegistry) CommandEncoder {
+	if format == FormatBinary {
+		return &binaryEncoder{w: w, registry: registry}
+	}
+	return &jsonEncoder{w: w, registry: registry}
+}
+
+func NewCommandDecoder(r io.Reader, format LogFormat, world *World, registry *CommandRegistry) CommandDecoder {
+	if format == FormatBinary {
+		return &binaryDecoder{r: bufio.NewReader(r), world: world, registry: registry}
+	}
+	return &jsonDecoder{r: bufio.NewReader(r), world: world, registry: registry}
+}
+
+// DetectFormat peeks at r without consuming it.
+func DetectFormat(r *bufio.Reader) (LogFormat, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return FormatJSON, err
+	}
+	switch b[0] {
+	case '{':
+		return FormatJSON, nil
+	case binaryVersion:
+		return FormatBinary, nil
+	}
+	return FormatJSON, fmt.Errorf("%w: leading byte 0x%02x", ErrUnsupportedFormat, b[0])
+}
+
+type jsonRecord struct {
+	Type    string                     `json:"type"`
+	Version int                        `json:"version"`
+	Data    map[string]json.RawMessage `json:"data,omitempty"`
+}
+
+type jsonEncoder struct {
+	w        io.Writer
+	registry *CommandRegistry
+}
+
+func (e *jsonEncoder) Encode(c Command) error {
+	codec, err := e.registry.codecOf(c)
+	if err != nil {
+		return err
+	}
+	fields := jsonFields{}
+	codec.Encode(c, fields)
+	line, err := json.Marshal(jsonRecord{Type: codec.Name, Version: codec.Version, Data: fields})
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(line, '\n'))
+	return err
+}
+
+type jsonFields map[string]json.RawMessage
+
+func (f jsonFields) String(name, v string) {
+	f[name], _ = json.Marshal(v)
+}
+
+func (f jsonFields) Int(name string, v int) {
+	f[name], _ = json.Marshal(v)
+}
+
+type jsonDecoder struct {
+	r        *bufio.Reader
+	world    *World
+	registry *CommandRegistry
+}
+
+func (d *jsonDecoder) Decode() (Command, error) {
+	line, err := d.r.ReadBytes('\n')
+	for err == nil && len(bytes.TrimSpace(line)) == 0 {
+		line, err = d.r.ReadBytes('\n')
+	}
+	if err == io.EOF {
+		if len(bytes.TrimSpace(line)) == 0 {
+			return nil, io.EOF
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec jsonRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return nil, fmt.Errorf("decode command record: %w", err)
+	}
+	codec, err := d.registry.lookup(rec.Type)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(d.world, rec.Version, &jsonFieldReader{fields: rec.Data})
+}
+
+type jsonFieldReader struct {
+	fields map[string]json.RawMessage
+	err    error
+}
+
+func (r *jsonFieldReader) read(name string, v interface{}) {
+	if r.err != nil {
+		return
+	}
+	raw, ok := r.fields[name]
+	if !ok {
+		r.err = fmt.Errorf("missing field %q", name)
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		r.err = fmt.Errorf("field %q: %w", name, err)
+	}
+}
+
+func (r *jsonFieldReader) String(name string) string {
+	var v string
+	r.read(name, &v)
+	return v
+}
+
+func (r *jsonFieldReader) Int(name string) int {
+	var v int
+	r.read(name, &v)
+	return v
+}
+
+func (r *jsonFieldReader) Err() error {
+	return r.err
+}
+
+// A binary record is the version byte, the uvarint length of the body,
+// and the body: type name, command version, then the fields in order.
+// Strings are uvarint length prefixed and ints are zig-zag varints.
+type binaryEncoder struct {
+	w        io.Writer
+	registry *CommandRegistry
+}
+
+func (e *binaryEncoder) Encode(c Command) error {
+	codec, err := e.registry.codecOf(c)
+	if err != nil {
+		return err
+	}
+	body := &binaryFields{}
+	body.String("", codec.Name)
+	body.Int("", codec.Version)
+	codec.Encode(c, body)
+	if len(body.buf) > maxRecordSize {
+		return fmt.Errorf("%w: %d byte record exceeds %d", ErrCorruptRecord, len(body.buf), maxRecordSize)
+	}
+
+	rec := make([]byte, 0, 1+binary.MaxVarintLen64+len(body.buf))
+	rec = append(rec, binaryVersion)
+	rec = binary.AppendUvarint(rec, uint64(len(body.buf)))
+	rec = append(rec, body.buf...)
+	_, err = e.w.Write(rec)
+	return err
+}
+
+type binaryFields struct {
+	buf []byte
+}
+
+func (f *binaryFields) String(_, v string) {
+	f.buf = binary.AppendUvarint(f.buf, uint64(len(v)))
+	f.buf = append(f.buf, v...)
+}
+
+func (f *binaryFields) Int(_ string, v int) {
+	f.buf = binary.AppendVarint(f.buf, int64(v))
+}
+
+type binaryDecoder struct {
+	r        *bufio.Reader
+	world    *World
+	registry *CommandRegistry
+}
+
+func (d *binaryDecoder) Decode() (Command, error) {
+	version, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if version != binaryVersion {
+		return nil, fmt.Errorf("%w: binary version %d", ErrUnsupportedFormat, version)
+	}
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if n > maxRecordSize {
+		return nil, fmt.Errorf("%w: %d byte record exceeds %d", ErrCorruptRecord, n, maxRecordSize)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(d.r, body); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	fields := &binaryFieldReader{buf: body}
+	name, cmdVersion := fields.String(""), fields.Int("")
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+	codec, err := d.registry.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(d.world, cmdVersion, fields)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type binaryFieldReader struct {
+	buf []byte
+	err error
+}
+
+func (r *binaryFieldReader) String(name string) string {
+	if r.err != nil {
+		return ""
+	}
+	n, size := binary.Uvarint(r.buf)
+	if size <= 0 || uint64(len(r.buf)-size) < n {
+		r.err = fmt.Errorf("field %q: %w", name, io.ErrUnexpectedEOF)
+		return ""
+	}
+	v := string(r.buf[size : size+int(n)])
+	r.buf = r.buf[size+int(n):]
+	return v
+}
+
+func (r *binaryFieldReader) Int(name string) int {
+	if r.err != nil {
+		return 0
+	}
+	v, size := binary.Varint(r.buf)
+	if size <= 0 {
+		r.err = fmt.Errorf("field %q: %w", name, io.ErrUnexpectedEOF)
+		return 0
+	}
+	r.buf = r.buf[size:]
+	return int(v)
+}
+
+func (r *binaryFieldReader) Err() error {
+	return r.err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func worldState(w *World) []PlayerState {
+	var states []PlayerState
+	for _, p := range w.Players() {
+		states = append(states, stateOf(p))
+	}
+	return states
+}
+
+func TestCommandLog_RoundTrip(t *testing.T) {
+	for _, format := range []LogFormat{FormatJSON, FormatBinary} {
+		world := NewWorld()
+		a := &Player{id: "a", world: world}
+		b := &Player{id: "b", world: world}
+		world.AddPlayer(a)
+		world.AddPlayer(b)
+		commands := []Command{
+			&SpawnCommand{world: world, id: "c", x: -3, y: 7, health: 55},
+			&MoveCommand{player: a, direction: "left"},
+			&AttackCommand{palyer: a, target: b},
+			undoMarker{},
+			macroEnd{},
+		}
+
+		var buf bytes.Buffer
+		enc := NewCommandEncoder(&buf, format, DefaultCommandRegistry())
+		for _, c := range commands {
+			if err := enc.Encode(c); err != nil {
+				t.Fatalf("format %d: encode %T: %v", format, c, err)
+			}
+		}
+
+		r := bytes.NewReader(buf.Bytes())
+		br := bufio.NewReader(r)
+		if got, err := DetectFormat(br); err != nil || got != format {
+			t.Fatalf("format %d: detected %d, %v", format, got, err)
+		}
+		dec := NewCommandDecoder(br, format, world, DefaultCommandRegistry())
+		for i, want := range commands {
+			got, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("format %d: decode %d: %v", format, i, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("format %d: record %d: expected %#v, got %#v", format, i, want, got)
+			}
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Fatalf("format %d: expected io.EOF, got %v", format, err)
+		}
+	}
+}
+
+func TestGameServer_Replay(t *testing.T) {
+	for _, format := range []LogFormat{FormatJSON, FormatBinary} {
+		s := NewGameServer(NewWorld())
+		var log bytes.Buffer
+		s.SetCommandLog(&log, format)
+		spawnPlayers(t, s, map[string][2]int{"a": {0, 0}, "b": {0, 2}})
+
+		a, b := mustPlayer(t, s.World(), "a"), mustPlayer(t, s.World(), "b")
+		s.AddCommands(
+			&MoveCommand{player: a, direction: "right"},
+			&AttackCommand{palyer: a, target: b},
+			&MoveCommand{player: b, direction: "up"},
+		)
+		s.ProcessCommands()
+		s.Undo()
+		s.Undo()
+		s.Redo()
+		s.AddCommands(NewMacroCommand(
+			&MoveCommand{player: a, direction: "up"},
+			&AttackCommand{palyer: a, target: b},
+		))
+		s.ProcessCommands()
+		s.Undo()
+
+		replayed := NewGameServer(NewWorld())
+		if err := replayed.Replay(bytes.NewReader(log.Bytes())); err != nil {
+			t.Fatalf("format %d: replay: %v", format, err)
+		}
+		if got, want := worldState(replayed.World()), worldState(s.World()); !reflect.DeepEqual(got, want) {
+			t.Fatalf("format %d: expected %v, got %v", format, want, got)
+		}
+		// The replayed history matches too, so the next undo agrees.
+		s.Undo()
+		replayed.Undo()
+		if got, want := worldState(replayed.World()), worldState(s.World()); !reflect.DeepEqual(got, want) {
+			t.Fatalf("format %d: after undo expected %v, got %v", format, want, got)
+		}
+	}
+}
+
+func TestGameServer_ReplayMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		markers []Command
+	}{
+		{"undo with empty history", []Command{undoMarker{}}},
+		{"redo with nothing undone", []Command{redoMarker{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log bytes.Buffer
+			enc := NewCommandEncoder(&log, FormatJSON, DefaultCommandRegistry())
+			for _, m := range tt.markers {
+				if err := enc.Encode(m); err != nil {
+					t.Fatalf("encode: %v", err)
+				}
+			}
+			err := NewGameServer(NewWorld()).Replay(&log)
+			if !errors.Is(err, ErrLogMismatch) {
+				t.Fatalf("expected ErrLogMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBinaryDecoder_CorruptRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		log  []byte
+		want error
+	}{
+		{"huge length", binary.AppendUvarint([]byte{binaryVersion}, 1<<62), ErrCorruptRecord},
+		{"over limit", binary.AppendUvarint([]byte{binaryVersion}, maxRecordSize+1), ErrCorruptRecord},
+		{"truncated length", []byte{binaryVersion, 0x80}, io.ErrUnexpectedEOF},
+		{"truncated body", append(binary.AppendUvarint([]byte{binaryVersion}, 10), 1, 2), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGameServer(NewWorld())
+			if err := s.Replay(bytes.NewReader(tt.log)); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import "sync"
+
+// PlayerCommand reports the players a command reads or writes, the
+// issuing player first. Commands that touch disjoint sets of players
+// can run in parallel within a tick.
+type PlayerCommand interface {
+	Command
+	Players() []string
+}
+
+func (c *MoveCommand) Players() []string {
+	return []string{c.player.id}
+}
+
+func (c *AttackCommand) Players() []string {
+	return []string{c.palyer.id, c.target.id}
+}
+
+func (c *SpawnCommand) Players() []string {
+	return []string{c.id}
+}
+
+func (m *MacroCommand) Players() []string {
+	var players []string
+	for _, c := range m.commands {
+		pc, ok := c.(PlayerCommand)
+		if !ok {
+			return nil
+		}
+		players = append(players, pc.Players()...)
+	}
+	return players
+}
+
+func playersOf(c Command) []string {
+	if pc, ok := c.(PlayerCommand); ok {
+		return pc.Players()
+	}
+	return nil
+}
+
+// queuedCommand remembers who submitted a command, which may differ
+// from the player the command acts for.
+type queuedCommand struct {
+	issuer  string
+	command Command
+}
+
+// CommandQueue is a thread-safe queue that keeps one FIFO lane per
+// acting player. Commands without players share the global lane.
+type CommandQueue struct {
+	mu     sync.Mutex
+	lanes  map[string][]queuedCommand
+	order  []string
+	size   int
+	budget int
+}
+
+func NewCommandQueue(budget int) *CommandQueue {
+	return &CommandQueue{lanes: make(map[string][]queuedCommand), budget: budget}
+}
+
+// Push queues commands submitted by issuer, "" meaning the server itself.
+func (q *CommandQueue) Push(issuer string, commands ...Command) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, c := range commands {
+		player := ""
+		if players := playersOf(c); len(players) > 0 {
+			player = players[0]
+		}
+		if _, ok := q.lanes[player]; !ok {
+			q.order = append(q.order, player)
+		}
+		q.lanes[player] = append(q.lanes[player], queuedCommand{issuer: issuer, command: c})
+		q.size++
+	}
+}
+
+func (q *CommandQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+type lane struct {
+	player   string
+	commands []queuedCommand
+}
+
+// take removes up to budget commands from every lane, in the order the
+// lanes first received a command.
+func (q *CommandQueue) take() []lane {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	lanes := make([]lane, 0, len(q.order))
+	order := q.order[:0]
+	for _, player := range q.order {
+		commands := q.lanes[player]
+		n := len(commands)
+		if q.budget > 0 && n > q.budget {
+			n = q.budget
+		}
+		lanes = append(lanes, lane{player: player, commands: commands[:n:n]})
+		q.size -= n
+		if n == len(commands) {
+			delete(q.lanes, player)
+			continue
+		}
+		q.lanes[player] = commands[n:]
+		order = append(order, player)
+	}
+	q.order = order
+	return lanes
+}
+
+// groupLanes merges lanes whose commands touch a common player, so each
+// group can run on its own goroutine without sharing players. The global
+// lane is returned separately.
+func groupLanes(lanes []lane) (global *lane, groups [][]lane) {
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(id string) string {
+		if p, ok := parent[id]; ok && p != id {
+			root := find(p)
+			parent[id] = root
+			return root
+		}
+		parent[id] = id
+		return id
+	}
+
+	for i := range lanes {
+		if lanes[i].player == "" {
+			global = &lanes[i]
+			continue
+		}
+		root := find(lanes[i].player)
+		for _, c := range lanes[i].commands {
+			for _, id := range playersOf(c.command) {
+				if r := find(id); r != root {
+					parent[r] = root
+				}
+			}
+		}
+	}
+
+	index := make(map[string]int)
+	for _, l := range lanes {
+		if l.player == "" {
+			continue
+		}
+		root := find(l.player)
+		i, ok := index[root]
+		if !ok {
+			i = len(groups)
+			index[root] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], l)
+	}
+	return global, groups
+}
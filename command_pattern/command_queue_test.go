@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestGroupLanes(t *testing.T) {
+	a, b, c, d := &Player{id: "a"}, &Player{id: "b"}, &Player{id: "c"}, &Player{id: "d"}
+	move := func(p *Player) queuedCommand { return queuedCommand{command: &MoveCommand{player: p}} }
+	attack := func(p, target *Player) queuedCommand {
+		return queuedCommand{command: &AttackCommand{palyer: p, target: target}}
+	}
+
+	tests := []struct {
+		name       string
+		lanes      []lane
+		wantGlobal bool
+		want       [][]string
+	}{
+		{
+			name:  "independent players",
+			lanes: []lane{{"a", []queuedCommand{move(a)}}, {"b", []queuedCommand{move(b)}}},
+			want:  [][]string{{"a"}, {"b"}},
+		},
+		{
+			name: "attack joins lanes",
+			lanes: []lane{
+				{"a", []queuedCommand{attack(a, c)}},
+				{"b", []queuedCommand{move(b)}},
+				{"c", []queuedCommand{move(c)}},
+			},
+			want: [][]string{{"a", "c"}, {"b"}},
+		},
+		{
+			name: "transitive",
+			lanes: []lane{
+				{"a", []queuedCommand{attack(a, b)}},
+				{"c", []queuedCommand{attack(c, d)}},
+				{"d", []queuedCommand{attack(d, b)}},
+			},
+			want: [][]string{{"a", "c", "d"}},
+		},
+		{
+			name:       "global lane",
+			lanes:      []lane{{"", []queuedCommand{{command: undoMarker{}}}}, {"a", []queuedCommand{move(a)}}},
+			wantGlobal: true,
+			want:       [][]string{{"a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global, groups := groupLanes(tt.lanes)
+			if (global != nil) != tt.wantGlobal {
+				t.Fatalf("expected global lane %v, got %v", tt.wantGlobal, global)
+			}
+			var got [][]string
+			for _, group := range groups {
+				var players []string
+				for _, l := range group {
+					players = append(players, l.player)
+				}
+				sort.Strings(players)
+				got = append(got, players)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGameServer_TickBudgetKeepsPlayerOrder(t *testing.T) {
+	s := NewGameServer(NewWorld(), WithTickBudget(2))
+	spawnPlayers(t, s, map[string][2]int{"a": {0, 0}, "b": {100, 0}, "c": {200, 0}})
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b", "c"} {
+		p := mustPlayer(t, s.World(), id)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				s.Submit(p.id, &MoveCommand{player: p, direction: "up"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	ticks := 0
+	for s.Pending() > 0 {
+		results, err := s.Tick()
+		if err != nil {
+			t.Fatalf("tick: %v", err)
+		}
+		if len(results) > 6 {
+			t.Fatalf("expected at most 2 commands per player per tick, got %d", len(results))
+		}
+		ticks++
+	}
+	if ticks != 3 {
+		t.Fatalf("expected 3 ticks, got %d", ticks)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if p := mustPlayer(t, s.World(), id); p.y != 5 {
+			t.Fatalf("expected %s to move 5 times, got %v", id, p)
+		}
+	}
+}
+
+func TestGameServer_ParallelTicksReplay(t *testing.T) {
+	for _, format := range []LogFormat{FormatJSON, FormatBinary} {
+		s := NewGameServer(NewWorld(WithBounds(4, 4)))
+		var log bytes.Buffer
+		s.SetCommandLog(&log, format)
+		positions := map[string][2]int{}
+		for i := 0; i < 8; i++ {
+			positions[fmt.Sprintf("p%d", i)] = [2]int{i % 4, i / 4}
+		}
+		spawnPlayers(t, s, positions)
+
+		// Players crowd a small map, so lanes running in parallel compete
+		// for the same tiles and some moves and macros fail.
+		directions := []string{"up", "right", "down", "left"}
+		for round := 0; round < 20; round++ {
+			for i, p := range s.World().Players() {
+				move := &MoveCommand{player: p, direction: directions[(i+round)%4]}
+				if i%3 == 0 {
+					macro := NewMacroCommand()
+					macro.Add(move, &MoveCommand{player: p, direction: directions[(i+round+1)%4]})
+					s.Submit(p.id, macro)
+					continue
+				}
+				s.Submit(p.id, move)
+			}
+			s.Tick()
+		}
+
+		replayed := NewGameServer(NewWorld(WithBounds(4, 4)))
+		if err := replayed.Replay(bytes.NewReader(log.Bytes())); err != nil {
+			t.Fatalf("format %d: replay: %v", format, err)
+		}
+		if got, want := worldState(replayed.World()), worldState(s.World()); !reflect.DeepEqual(got, want) {
+			t.Fatalf("format %d: expected %v, got %v", format, want, got)
+		}
+	}
+}
@@ -3,17 +3,58 @@ package main
 type MoveCommand struct {
 	player    *Player
 	direction string
+
+	prevX, prevY int
+}
+
+func (c *MoveCommand) Execute() error {
+	c.prevX, c.prevY = c.player.x, c.player.y
+	return c.player.Move(c.direction)
 }
 
-func (c *MoveCommand) Execute() {
-	c.player.Move(c.direction)
+func (c *MoveCommand) Undo() {
+	c.player.setPosition(c.prevX, c.prevY)
 }
 
 type AttackCommand struct {
 	palyer *Player
 	target *Player
+
+	prevHealth int
 }
 
-func (c *AttackCommand) Execute() {
+func (c *AttackCommand) Execute() error {
+	c.prevHealth = c.target.health
 	c.palyer.Attack(c.target)
+	return nil
+}
+
+func (c *AttackCommand) Undo() {
+	c.target.health = c.prevHealth
+}
+
+type SpawnCommand struct {
+	world  *World
+	id     string
+	x, y   int
+	health int
+
+	prev *Player
+}
+
+func (c *SpawnCommand) Execute() error {
+	prev, _ := c.world.Player(c.id)
+	if err := c.world.spawn(&Player{id: c.id, x: c.x, y: c.y, health: c.health}); err != nil {
+		return err
+	}
+	c.prev = prev
+	return nil
+}
+
+func (c *SpawnCommand) Undo() {
+	if c.prev != nil {
+		c.world.AddPlayer(c.prev)
+		return
+	}
+	c.world.RemovePlayer(c.id)
 }
@@ -1,39 +1,469 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type TickStats struct {
+	Tick     uint64
+	Accepted int
+	Rejected int
+	Failed   int
+	Pending  int
+	Duration time.Duration
+	Results  []CommandResult
+}
+
+var (
+	// ErrCommandLog wraps failures to write the command log. They stop
+	// Run, since the world would otherwise diverge from the log.
+	ErrCommandLog = errors.New("write command log")
+
+	// ErrNotUndoable rejects commands that cannot be undone while a log
+	// is set, since they could not be reverted if logging them failed.
+	ErrNotUndoable = errors.New("command cannot be undone")
+
+	// ErrLogMismatch is returned by Replay for a log that does not fit
+	// the history it rebuilds, e.g. an undo with nothing to undo.
+	ErrLogMismatch = errors.New("command log does not match the history")
 )
 
 type GameServer struct {
-	commands []Command
+	world    *World
+	queue    *CommandQueue
+	registry *CommandRegistry
+	policy   Policy
+	clock    Clock
+
+	scheduler *Scheduler
+
+	// tickMu serializes ticks with undo, redo, replay and changes of the
+	// log. mu guards the history and the log. applyMu lets the lanes of a
+	// tick execute in parallel, except for macros, which run alone.
+	tickMu    sync.Mutex
+	mu        sync.Mutex
+	applyMu   sync.RWMutex
+	history   *History
+	log       io.Writer
+	logFormat LogFormat
+
+	tick       uint64
+	beforeTick []func(tick uint64)
+	afterTick  []func(stats TickStats)
+}
+
+func NewGameServer(world *World, options ...*Option) *GameServer {
+	opts := defaultServerOptions()
+	for _, option := range options {
+		option.apply(opts)
+	}
+	s := &GameServer{
+		world:    world,
+		queue:    NewCommandQueue(opts.tickBudget),
+		history:  NewHistory(opts.historyDepth),
+		registry: DefaultCommandRegistry(),
+		policy:   opts.policy,
+		clock:    opts.clock,
+	}
+	s.scheduler = NewScheduler(opts.clock, s.queue.Push)
+	return s
+}
+
+func (s *GameServer) World() *World {
+	return s.world
+}
+
+// Registry returns the command registry used for the log, so custom
+// command types can be registered before logging or replaying them.
+func (s *GameServer) Registry() *CommandRegistry {
+	return s.registry
+}
+
+// SetCommandLog makes the server append every step to w once it has
+// been applied. Each step is a single Write, so a macro is never logged
+// in part. Pass nil to stop logging.
+func (s *GameServer) SetCommandLog(w io.Writer, format LogFormat) {
+	s.tickMu.Lock()
+	defer s.tickMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log, s.logFormat = w, format
+}
+
+// OnBeforeTick and OnAfterTick register tick hooks. They are not safe to
+// call while the server is running.
+func (s *GameServer) OnBeforeTick(hook func(tick uint64)) {
+	s.beforeTick = append(s.beforeTick, hook)
+}
+
+func (s *GameServer) OnAfterTick(hook func(stats TickStats)) {
+	s.afterTick = append(s.afterTick, hook)
 }
 
+// AddCommands queues commands issued by the server itself. It is safe
+// to call from many goroutines.
 func (s *GameServer) AddCommands(commands ...Command) {
-	s.commands = append(s.commands, commands...)
+	s.queue.Push("", commands...)
 }
 
-func (s *GameServer) ProcessCommands() {
-	for _, c := range s.commands {
-		c.Execute()
+// Submit queues commands issued by a player. The policy authorizes them
+// when they run.
+func (s *GameServer) Submit(issuer string, commands ...Command) {
+	s.queue.Push(issuer, commands...)
+}
+
+func (s *GameServer) Pending() int {
+	return s.queue.Len()
+}
+
+// Schedule queues c for issuer once it is due according to the server
+// clock. Due commands are picked up at the start of a tick.
+func (s *GameServer) Schedule(issuer string, c Command, options ...*ScheduleOption) (*CancelToken, error) {
+	return s.scheduler.Schedule(issuer, c, options...)
+}
+
+// Tick runs one batch of queued commands and reports the result of
+// each. Every player's commands run in FIFO order, and players that do
+// not interact run in parallel. Commands without players run first.
+// The returned error joins the failures.
+func (s *GameServer) Tick() ([]CommandResult, error) {
+	s.tickMu.Lock()
+	defer s.tickMu.Unlock()
+
+	s.tick++
+	tick := s.tick
+	start := s.clock.Now()
+	for _, hook := range s.beforeTick {
+		hook(tick)
+	}
+	s.scheduler.Poll()
+
+	global, groups := groupLanes(s.queue.take())
+
+	runs := make([]laneRun, len(groups)+1)
+	if global != nil {
+		s.runLanes(tick, []lane{*global}, &runs[0], 0)
+	}
+
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		i, group := i+1, group
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runLanes(tick, group, &runs[i], i)
+		}()
 	}
+	wg.Wait()
+	results := s.commit(runs)
+
+	stats := TickStats{
+		Tick:     tick,
+		Pending:  s.queue.Len(),
+		Duration: s.clock.Now().Sub(start),
+		Results:  results,
+	}
+	var errs []error
+	for _, r := range results {
+		switch r.Status {
+		case StatusAccepted:
+			stats.Accepted++
+		case StatusRejected:
+			stats.Rejected++
+		case StatusFailed:
+			stats.Failed++
+			errs = append(errs, r.Err)
+		}
+	}
+	for _, hook := range s.afterTick {
+		hook(stats)
+	}
+	return results, errors.Join(errs...)
 }
 
-func main() {
-	server := GameServer{}
+// laneRun collects the results of the lanes one goroutine ran in a tick.
+type laneRun struct {
+	results []CommandResult
+	applied []appliedCommand
+}
 
-	player1 := &Player{}
-	player2 := &Player{}
+// appliedCommand points at a result whose command changed the world and
+// still has to be logged. version is the world version it was applied
+// at, or the one of the command before it if it did not move anyone.
+type appliedCommand struct {
+	run, index int
+	version    uint64
+}
 
-	moveCommand1 := &MoveCommand{player1, "right"}
-	attackCommand1 := &AttackCommand{player1, player2}
+func (s *GameServer) runLanes(tick uint64, lanes []lane, run *laneRun, n int) {
+	var version uint64
+	for _, l := range lanes {
+		for _, q := range l.commands {
+			result := s.execute(tick, q)
+			if result.Status == StatusAccepted {
+				if v := s.world.versionOf(playersOf(q.command)); v > version {
+					version = v
+				}
+				run.applied = append(run.applied, appliedCommand{run: n, index: len(run.results), version: version})
+			}
+			run.results = append(run.results, result)
+		}
+	}
+}
 
-	moveCommand2 := &MoveCommand{player2, "up"}
-	attackCommand2 := &AttackCommand{player2, player1}
+// commit logs and records the commands of a tick in the order they
+// changed the world, so replaying the log one command at a time gives
+// the same result as the parallel lanes. Commands of different lanes
+// only depend on each other through the tiles they take and leave, and
+// every such change has its own world version.
+func (s *GameServer) commit(runs []laneRun) []CommandResult {
+	var applied []appliedCommand
+	for _, run := range runs {
+		applied = append(applied, run.applied...)
+	}
+	sort.SliceStable(applied, func(i, j int) bool { return applied[i].version < applied[j].version })
 
-	server.AddCommands(moveCommand1, attackCommand1, moveCommand2, attackCommand2)
+	s.mu.Lock()
+	for i, a := range applied {
+		c := runs[a.run].results[a.index].Command
+		if s.log != nil {
+			if err := s.logCommand(c); err != nil {
+				s.revert(runs, applied[i:], fmt.Errorf("%w: %w", ErrCommandLog, err))
+				break
+			}
+		}
+		s.history.Record(c)
+	}
+	s.mu.Unlock()
 
-	server.ProcessCommands()
+	var results []CommandResult
+	for _, run := range runs {
+		results = append(results, run.results...)
+	}
+	return results
+}
+
+// revert undoes the commands that could not be logged, latest first, so
+// the world never gets ahead of the log.
+func (s *GameServer) revert(runs []laneRun, applied []appliedCommand, err error) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		result := &runs[applied[i].run].results[applied[i].index]
+		if u, ok := result.Command.(UndoableCommand); ok {
+			u.Undo()
+		}
+		result.Status = StatusFailed
+		result.Err = err
+	}
+}
+
+// ProcessCommands runs ticks until the queue is empty, or stops early
+// if the command log fails. The returned error joins the failures.
+func (s *GameServer) ProcessCommands() ([]CommandResult, error) {
+	var all []CommandResult
+	var errs []error
+	for s.queue.Len() > 0 {
+		results, err := s.Tick()
+		all = append(all, results...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if errors.Is(err, ErrCommandLog) {
+			break
+		}
+	}
+	return all, errors.Join(errs...)
+}
+
+// Run calls Tick every interval until ctx is done or the command log
+// fails. Commands that fail to apply are only reported in the results
+// and the tick stats.
+func (s *GameServer) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.Tick(); errors.Is(err, ErrCommandLog) {
+				return err
+			}
+		}
+	}
+}
+
+// logCommand encodes c, and the children of a macro between their
+// markers, and writes all of it at once.
+func (s *GameServer) logCommand(c Command) error {
+	var buf bytes.Buffer
+	if err := encodeCommand(NewCommandEncoder(&buf, s.logFormat, s.registry), c); err != nil {
+		return err
+	}
+	_, err := s.log.Write(buf.Bytes())
+	return err
+}
+
+func encodeCommand(e CommandEncoder, c Command) error {
+	m, ok := c.(*MacroCommand)
+	if !ok {
+		return e.Encode(c)
+	}
+	if err := e.Encode(macroBegin{}); err != nil {
+		return err
+	}
+	for _, child := range m.commands {
+		if err := encodeCommand(e, child); err != nil {
+			return err
+		}
+	}
+	return e.Encode(macroEnd{})
+}
+
+// execute authorizes, validates and runs one command. The commands that
+// were applied are logged and recorded by commit.
+func (s *GameServer) execute(tick uint64, q queuedCommand) CommandResult {
+	result := CommandResult{Tick: tick, Issuer: q.issuer, Command: q.command, Status: StatusRejected}
+	if err := s.policy.Authorize(q.issuer, q.command); err != nil {
+		result.Err = err
+		return result
+	}
+	if v, ok := q.command.(ValidatableCommand); ok {
+		if err := v.Validate(s.world); err != nil {
+			result.Err = err
+			return result
+		}
+	}
+	// The log only changes between ticks, so it can be read here.
+	if _, ok := q.command.(UndoableCommand); !ok && s.log != nil {
+		result.Err = fmt.Errorf("%w: %T", ErrNotUndoable, q.command)
+		return result
+	}
 
-	fmt.Println(player1)
-	fmt.Println(player2)
+	// A macro changes several tiles one after another. Running it alone
+	// keeps other lanes from taking a tile in between, which could not
+	// be replayed in any single order.
+	if _, ok := q.command.(*MacroCommand); ok {
+		s.applyMu.Lock()
+		defer s.applyMu.Unlock()
+	} else {
+		s.applyMu.RLock()
+		defer s.applyMu.RUnlock()
+	}
+	if err := q.command.Execute(); err != nil {
+		result.Status = StatusFailed
+		result.Err = err
+		return result
+	}
+	result.Status = StatusAccepted
+	return result
+}
+
+// Undo reverts the latest step and reports whether there was one. If
+// the undo marker cannot be logged, nothing is undone and an error
+// wrapping ErrCommandLog is returned.
+func (s *GameServer) Undo() (bool, error) {
+	s.tickMu.Lock()
+	defer s.tickMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.history.CanUndo() {
+		return false, nil
+	}
+	// Undoing cannot fail, so the marker can be logged first.
+	if err := s.logMarker(undoMarker{}); err != nil {
+		return false, err
+	}
+	return s.history.Undo(), nil
+}
+
+// Redo applies the latest undone step again and reports whether there
+// was one. A step that fails to apply stays undone and its error is
+// returned. If the redo marker cannot be logged, the step is undone
+// again and an error wrapping ErrCommandLog is returned.
+func (s *GameServer) Redo() (bool, error) {
+	s.tickMu.Lock()
+	defer s.tickMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Redoing can fail, so the marker is only logged once it succeeded.
+	if ok, err := s.history.Redo(); !ok {
+		return false, err
+	}
+	if err := s.logMarker(redoMarker{}); err != nil {
+		s.history.Undo()
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *GameServer) logMarker(marker Command) error {
+	if s.log == nil {
+		return nil
+	}
+	if err := s.logCommand(marker); err != nil {
+		return fmt.Errorf("%w: %w", ErrCommandLog, err)
+	}
+	return nil
+}
+
+// Replay applies every command of a log written by SetCommandLog to the
+// server's world. The format is detected from the first byte. Replaying
+// into an empty world rebuilds the players from their spawn commands.
+func (s *GameServer) Replay(r io.Reader) error {
+	s.tickMu.Lock()
+	defer s.tickMu.Unlock()
+
+	br := bufio.NewReader(r)
+	format, err := DetectFormat(br)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	d := NewCommandDecoder(br, format, s.world, s.registry)
+	for i := 0; ; i++ {
+		c, err := d.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("replay record %d: %w", i, err)
+		}
+		s.mu.Lock()
+		switch c.(type) {
+		case macroBegin:
+			s.history.BeginMacro()
+		case macroEnd:
+			s.history.EndMacro()
+		case undoMarker:
+			if !s.history.Undo() {
+				err = fmt.Errorf("%w: nothing to undo", ErrLogMismatch)
+			}
+		case redoMarker:
+			var ok bool
+			if ok, err = s.history.Redo(); !ok && err == nil {
+				err = fmt.Errorf("%w: nothing to redo", ErrLogMismatch)
+			}
+		default:
+			err = s.history.Execute(c)
+		}
+		s.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("replay record %d: %w", i, err)
+		}
+	}
 }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// spawnPlayers adds players at the given positions through the server,
+// so they are also written to the command log.
+func spawnPlayers(t *testing.T, s *GameServer, positions map[string][2]int) {
+	t.Helper()
+	for id, pos := range positions {
+		s.AddCommands(&SpawnCommand{world: s.World(), id: id, x: pos[0], y: pos[1], health: 100})
+	}
+	if _, err := s.ProcessCommands(); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+}
+
+func mustPlayer(t *testing.T, w *World, id string) *Player {
+	t.Helper()
+	p, ok := w.Player(id)
+	if !ok {
+		t.Fatalf("player %q not found", id)
+	}
+	return p
+}
+
+func TestGameServer_FailedExecute(t *testing.T) {
+	s := NewGameServer(NewWorld())
+	spawnPlayers(t, s, map[string][2]int{"a": {0, 0}, "b": {2, 0}})
+	var log bytes.Buffer
+	s.SetCommandLog(&log, FormatJSON)
+
+	a, b := mustPlayer(t, s.World(), "a"), mustPlayer(t, s.World(), "b")
+	steps := len(s.history.undo)
+	// Both steps validate against the same world, but b cannot enter the
+	// tile a has just moved onto.
+	s.AddCommands(NewMacroCommand(
+		&MoveCommand{player: a, direction: "right"},
+		&MoveCommand{player: b, direction: "left"},
+	))
+	results, err := s.ProcessCommands()
+	if !errors.Is(err, ErrOccupied) {
+		t.Fatalf("expected ErrOccupied, got %v", err)
+	}
+	if len(results) != 1 || results[0].Status != StatusFailed {
+		t.Fatalf("expected one failed result, got %v", results)
+	}
+	if a.x != 0 || b.x != 2 {
+		t.Fatalf("expected the macro to be rolled back, got %v %v", a, b)
+	}
+	if log.Len() != 0 {
+		t.Fatalf("expected nothing logged, got %q", log.String())
+	}
+	if len(s.history.undo) != steps {
+		t.Fatalf("expected the failed command to stay out of the history")
+	}
+}
+
+// failingWriter accepts the first n writes and fails the rest. A
+// negative n never fails.
+type failingWriter struct {
+	bytes.Buffer
+	n int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errDiskFull
+	}
+	w.n--
+	return w.Buffer.Write(p)
+}
+
+func TestGameServer_LogFailure(t *testing.T) {
+	for accepted := 0; accepted <= 4; accepted++ {
+		s := NewGameServer(NewWorld())
+		log := &failingWriter{n: 3 + accepted}
+		s.SetCommandLog(log, FormatBinary)
+		spawnPlayers(t, s, map[string][2]int{"a": {0, 0}, "b": {10, 0}, "c": {20, 0}})
+
+		a, b, c := mustPlayer(t, s.World(), "a"), mustPlayer(t, s.World(), "b"), mustPlayer(t, s.World(), "c")
+		s.Submit("a", &MoveCommand{player: a, direction: "up"}, &MoveCommand{player: a, direction: "up"})
+		s.Submit("b", NewMacroCommand(
+			&MoveCommand{player: b, direction: "up"},
+			&MoveCommand{player: b, direction: "right"},
+		))
+		s.Submit("c", &MoveCommand{player: c, direction: "up"})
+		results, err := s.Tick()
+		if accepted < 4 && !errors.Is(err, errDiskFull) {
+			t.Fatalf("accepted %d: expected the log error, got %v", accepted, err)
+		}
+
+		failed := 0
+		for _, r := range results {
+			if r.Status == StatusFailed {
+				if !errors.Is(r.Err, ErrCommandLog) {
+					t.Fatalf("accepted %d: expected ErrCommandLog, got %v", accepted, r.Err)
+				}
+				failed++
+			}
+		}
+		if failed != 4-accepted {
+			t.Fatalf("accepted %d: expected %d failed commands, got %v", accepted, 4-accepted, results)
+		}
+
+		// Whatever made it into the log, the world matches it, including
+		// no half written macro.
+		replayed := NewGameServer(NewWorld())
+		if err := replayed.Replay(bytes.NewReader(log.Bytes())); err != nil {
+			t.Fatalf("accepted %d: replay: %v", accepted, err)
+		}
+		if got, want := worldState(replayed.World()), worldState(s.World()); !reflect.DeepEqual(got, want) {
+			t.Fatalf("accepted %d: expected %v, got %v", accepted, want, got)
+		}
+	}
+}
+
+// teleport is a command that cannot be undone.
+type teleport struct {
+	player *Player
+}
+
+func (c *teleport) Execute() error {
+	c.player.setPosition(5, 5)
+	return nil
+}
+
+func (c *teleport) Players() []string {
+	return []string{c.player.id}
+}
+
+func TestGameServer_RejectsNotUndoableWhileLogging(t *testing.T) {
+	s := NewGameServer(NewWorld())
+	spawnPlayers(t, s, map[string][2]int{"a": {0, 0}})
+	a := mustPlayer(t, s.World(), "a")
+
+	var log bytes.Buffer
+	s.SetCommandLog(&log, FormatJSON)
+	s.AddCommands(&teleport{player: a})
+	results, _ := s.ProcessCommands()
+	if len(results) != 1 || results[0].Status != StatusRejected || !errors.Is(results[0].Err, ErrNotUndoable) {
+		t.Fatalf("expected ErrNotUndoable, got %v", results)
+	}
+	if a.x != 0 || log.Len() != 0 {
+		t.Fatalf("expected nothing applied or logged, got %v and %q", a, log.String())
+	}
+
+	s.SetCommandLog(nil, FormatJSON)
+	s.AddCommands(&teleport{player: a})
+	if results, _ := s.ProcessCommands(); results[0].Status != StatusAccepted || a.x != 5 {
+		t.Fatalf("expected the command to run without a log, got %v", results)
+	}
+}
+
+func TestGameServer_UndoRedo(t *testing.T) {
+	tests := []struct {
+		name string
+		// run gets the server after a moved right, and returns what a
+		// last Undo or Redo returned.
+		run      func(s *GameServer, log *failingWriter) (bool, error)
+		wantOK   bool
+		wantErr  error
+		wantX    int
+		wantRedo bool
+	}{
+		{
+			name:   "undo",
+			run:    func(s *GameServer, _ *failingWriter) (bool, error) { return s.Undo() },
+			wantOK: true, wantX: 0, wantRedo: true,
+		},
+		{
+			name: "undo log fails",
+			run: func(s *GameServer, log *failingWriter) (bool, error) {
+				log.n = 0
+				return s.Undo()
+			},
+			wantErr: ErrCommandLog, wantX: 1,
+		},
+		{
+			name: "redo",
+			run: func(s *GameServer, _ *failingWriter) (bool, error) {
+				s.Undo()
+				return s.Redo()
+			},
+			wantOK: true, wantX: 1,
+		},
+		{
+			name:  "redo nothing",
+			run:   func(s *GameServer, _ *failingWriter) (bool, error) { return s.Redo() },
+			wantX: 1,
+		},
+		{
+			name: "redo fails to apply",
+			run: func(s *GameServer, _ *failingWriter) (bool, error) {
+				s.Undo()
+				s.World().SetBlocked(1, 0, true)
+				return s.Redo()
+			},
+			wantErr: ErrBlocked, wantX: 0, wantRedo: true,
+		},
+		{
+			name: "redo log fails",
+			run: func(s *GameServer, log *failingWriter) (bool, error) {
+				s.Undo()
+				log.n = 0
+				return s.Redo()
+			},
+			wantErr: ErrCommandLog, wantX: 0, wantRedo: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGameServer(NewWorld())
+			log := &failingWriter{n: -1}
+			s.SetCommandLog(log, FormatJSON)
+			spawnPlayers(t, s, map[string][2]int{"a": {0, 0}})
+			a := mustPlayer(t, s.World(), "a")
+			s.AddCommands(&MoveCommand{player: a, direction: "right"})
+			if _, err := s.ProcessCommands(); err != nil {
+				t.Fatalf("move: %v", err)
+			}
+
+			ok, err := tt.run(s, log)
+			if ok != tt.wantOK || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, %v, got %v, %v", tt.wantOK, tt.wantErr, ok, err)
+			}
+			if a.x != tt.wantX || s.history.CanRedo() != tt.wantRedo {
+				t.Fatalf("expected x=%d canRedo=%v, got %v canRedo=%v", tt.wantX, tt.wantRedo, a, s.history.CanRedo())
+			}
+
+			// Only the steps that took effect are logged.
+			replayed := NewGameServer(NewWorld())
+			if err := replayed.Replay(bytes.NewReader(log.Bytes())); err != nil {
+				t.Fatalf("replay: %v", err)
+			}
+			if got, want := worldState(replayed.World()), worldState(s.World()); !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
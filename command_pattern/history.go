@@ -0,0 +1,147 @@
+package main
+
+import "fmt"
+
+type MacroCommand struct {
+	commands []UndoableCommand
+}
+
+func NewMacroCommand(commands ...UndoableCommand) *MacroCommand {
+	return &MacroCommand{commands: commands}
+}
+
+func (m *MacroCommand) Add(commands ...UndoableCommand) {
+	m.commands = append(m.commands, commands...)
+}
+
+// Execute runs the children in order. If one fails, the children that
+// already ran are undone, so the macro applies completely or not at all.
+func (m *MacroCommand) Execute() error {
+	for i, c := range m.commands {
+		if err := c.Execute(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				m.commands[j].Undo()
+			}
+			return fmt.Errorf("macro step %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (m *MacroCommand) Undo() {
+	for i := len(m.commands) - 1; i >= 0; i-- {
+		m.commands[i].Undo()
+	}
+}
+
+// History keeps the undo and redo stacks of executed commands.
+// depth <= 0 means unlimited.
+type History struct {
+	depth int
+	undo  []UndoableCommand
+	redo  []UndoableCommand
+
+	macro      *MacroCommand
+	macroDepth int
+}
+
+func NewHistory(depth int) *History {
+	return &History{depth: depth}
+}
+
+// Execute runs c and records it if it succeeds. A command that cannot
+// be undone clears the history, since the earlier steps can no longer
+// be reverted consistently.
+func (h *History) Execute(c Command) error {
+	if err := c.Execute(); err != nil {
+		return err
+	}
+	h.Record(c)
+	return nil
+}
+
+// Record adds a command that has already been executed.
+func (h *History) Record(c Command) {
+	u, ok := c.(UndoableCommand)
+	if !ok {
+		h.Clear()
+		return
+	}
+	if h.macro != nil {
+		h.macro.Add(u)
+		return
+	}
+	h.push(u)
+}
+
+func (h *History) push(c UndoableCommand) {
+	h.redo = nil
+	h.undo = append(h.undo, c)
+	if h.depth > 0 && len(h.undo) > h.depth {
+		h.undo = h.undo[len(h.undo)-h.depth:]
+	}
+}
+
+// BeginMacro groups every command executed until the matching EndMacro
+// into a single undo step. Calls may be nested.
+func (h *History) BeginMacro() {
+	if h.macroDepth == 0 {
+		h.macro = NewMacroCommand()
+	}
+	h.macroDepth++
+}
+
+func (h *History) EndMacro() {
+	if h.macroDepth == 0 {
+		return
+	}
+	h.macroDepth--
+	if h.macroDepth > 0 {
+		return
+	}
+	macro := h.macro
+	h.macro = nil
+	if len(macro.commands) > 0 {
+		h.push(macro)
+	}
+}
+
+func (h *History) Undo() bool {
+	if len(h.undo) == 0 {
+		return false
+	}
+	c := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	c.Undo()
+	h.redo = append(h.redo, c)
+	return true
+}
+
+// Redo reports false if there is nothing to redo, or if the step fails
+// to apply, in which case it stays on the redo stack and the error is
+// returned.
+func (h *History) Redo() (bool, error) {
+	if len(h.redo) == 0 {
+		return false, nil
+	}
+	c := h.redo[len(h.redo)-1]
+	if err := c.Execute(); err != nil {
+		return false, err
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, c)
+	return true, nil
+}
+
+func (h *History) CanUndo() bool {
+	return len(h.undo) > 0
+}
+
+func (h *History) CanRedo() bool {
+	return len(h.redo) > 0
+}
+
+func (h *History) Clear() {
+	h.undo = nil
+	h.redo = nil
+}
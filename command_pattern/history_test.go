@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestHistory_UndoRedoDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		depth    int
+		moves    int
+		undos    int
+		redos    int
+		wantX    int
+		wantUndo bool
+		wantRedo bool
+	}{
+		{"undo all", 0, 3, 3, 0, 0, false, true},
+		{"undo then redo", 0, 3, 2, 1, 2, true, true},
+		{"depth limits undo", 2, 5, 5, 0, 3, false, true},
+		{"redo past end", 0, 2, 1, 3, 2, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(tt.depth)
+			p := &Player{id: "p"}
+			for i := 0; i < tt.moves; i++ {
+				if err := h.Execute(&MoveCommand{player: p, direction: "right"}); err != nil {
+					t.Fatalf("execute: %v", err)
+				}
+			}
+			for i := 0; i < tt.undos; i++ {
+				h.Undo()
+			}
+			for i := 0; i < tt.redos; i++ {
+				h.Redo()
+			}
+			if p.x != tt.wantX || h.CanUndo() != tt.wantUndo || h.CanRedo() != tt.wantRedo {
+				t.Fatalf("got x=%d canUndo=%v canRedo=%v", p.x, h.CanUndo(), h.CanRedo())
+			}
+		})
+	}
+}
+
+func TestHistory_Macro(t *testing.T) {
+	h := NewHistory(0)
+	p, q := &Player{id: "p"}, &Player{id: "q", health: 100}
+
+	h.BeginMacro()
+	h.Execute(&MoveCommand{player: p, direction: "up"})
+	h.BeginMacro()
+	h.Execute(&AttackCommand{palyer: p, target: q})
+	h.EndMacro()
+	h.EndMacro()
+	h.Execute(&MoveCommand{player: p, direction: "right"})
+
+	h.Undo()
+	h.Undo()
+	if p.x != 0 || p.y != 0 || q.health != 100 || h.CanUndo() {
+		t.Fatalf("expected nested macro to undo as one step, got %v %v", p, q)
+	}
+
+	// A new command clears the redo stack.
+	h.Redo()
+	h.Execute(&MoveCommand{player: p, direction: "down"})
+	if h.CanRedo() {
+		t.Fatalf("expected redo stack to be cleared")
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+func main() {
+	mode := flag.String("mode", "demo", "demo, serve, bot or load")
+	addr := flag.String("addr", "127.0.0.1:7000", "server address")
+	tick := flag.Duration("tick", 50*time.Millisecond, "server tick interval")
+	bots := flag.Int("bots", 10, "number of bots")
+	rate := flag.Duration("rate", 100*time.Millisecond, "interval between bot actions")
+	duration := flag.Duration("duration", 5*time.Second, "how long bots play, 0 means until interrupted")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch *mode {
+	case "demo":
+		runDemo()
+	case "serve":
+		err = serve(ctx, *addr, *tick)
+	case "bot":
+		err = runBots(ctx, *addr, *bots, *rate, *duration)
+	case "load":
+		err = runLoad(ctx, *tick, *bots, *rate, *duration)
+	default:
+		err = fmt.Errorf("unknown mode %q", *mode)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newNetworkGame() *GameServer {
+	world := NewWorld(WithBounds(64, 64), WithAttackRange(5))
+	return NewGameServer(world, WithPolicy(OwnPlayerOnly), WithTickBudget(4), WithHistoryDepth(1))
+}
+
+func serve(ctx context.Context, addr string, tick time.Duration) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return serveOn(ctx, l, newNetworkGame(), tick)
+}
+
+func serveOn(ctx context.Context, l net.Listener, game *GameServer, tick time.Duration) error {
+	front := NewNetServer(game)
+	go front.Serve(l)
+	defer front.Close()
+
+	fmt.Println("listening on", l.Addr())
+	if err := game.Run(ctx, tick); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func runBots(ctx context.Context, addr string, n int, rate, duration time.Duration) error {
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var total BotStats
+	var firstErr error
+	for i := 0; i < n; i++ {
+		bot := NewBot(fmt.Sprintf("bot%d", i), rate, int64(i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats, err := bot.Run(ctx, addr)
+			mu.Lock()
+			defer mu.Unlock()
+			total.Sent += stats.Sent
+			total.Accepted += stats.Accepted
+			total.Rejected += stats.Rejected
+			total.Snapshots += stats.Snapshots
+			total.Deltas += stats.Deltas
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	fmt.Printf("%d bots: sent %d, accepted %d, rejected %d, snapshots %d, deltas %d\n",
+		n, total.Sent, total.Accepted, total.Rejected, total.Snapshots, total.Deltas)
+	return firstErr
+}
+
+// runLoad starts a server on a random local port and points the bots
+// at it, for load testing on one machine.
+func runLoad(ctx context.Context, tick time.Duration, n int, rate, duration time.Duration) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- serveOn(ctx, l, newNetworkGame(), tick) }()
+
+	err = runBots(ctx, l.Addr().String(), n, rate, duration)
+	cancel()
+	if serveErr := <-done; err == nil {
+		err = serveErr
+	}
+	return err
+}
+
+func runDemo() {
+	server := NewGameServer(NewWorld(), WithHistoryDepth(10))
+
+	var log bytes.Buffer
+	server.SetCommandLog(&log, FormatJSON)
+
+	server.AddCommands(
+		&SpawnCommand{world: server.World(), id: "player1", health: 100},
+		&SpawnCommand{world: server.World(), id: "player2", y: 2, health: 100},
+	)
+	if _, err := server.ProcessCommands(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	player1, _ := server.World().Player("player1")
+	player2, _ := server.World().Player("player2")
+
+	moveCommand1 := &MoveCommand{player: player1, direction: "right"}
+	attackCommand1 := &AttackCommand{palyer: player1, target: player2}
+
+	moveCommand2 := &MoveCommand{player: player2, direction: "up"}
+	attackCommand2 := &AttackCommand{palyer: player2, target: player1}
+
+	server.AddCommands(moveCommand1, attackCommand1, moveCommand2, attackCommand2)
+
+	if _, err := server.ProcessCommands(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(player1)
+	fmt.Println(player2)
+
+	server.Undo()
+	server.Undo()
+	fmt.Println("after undo:", player1, player2)
+
+	server.Redo()
+	fmt.Println("after redo:", player1, player2)
+
+	combo := NewMacroCommand(
+		&MoveCommand{player: player1, direction: "up"},
+		&AttackCommand{palyer: player1, target: player2},
+	)
+	server.AddCommands(combo)
+	if _, err := server.ProcessCommands(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("after combo:", player1, player2)
+
+	server.Undo()
+	fmt.Println("after undo combo:", player1, player2)
+
+	fmt.Print("command log:\n", log.String())
+
+	replayed := NewGameServer(NewWorld(), WithHistoryDepth(10))
+	if err := replayed.Replay(&log); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, p := range replayed.World().Players() {
+		fmt.Println("replayed:", p)
+	}
+
+	runTicks()
+	runSchedule()
+	runWorld()
+}
+
+func runTicks() {
+	server := NewGameServer(NewWorld(), WithTickBudget(2), WithPolicy(OwnPlayerOnly))
+	server.OnAfterTick(func(stats TickStats) {
+		fmt.Printf("tick %d: accepted %d, rejected %d, pending %d\n", stats.Tick, stats.Accepted, stats.Rejected, stats.Pending)
+		for _, r := range stats.Results {
+			if r.Status != StatusAccepted {
+				fmt.Println(" ", r)
+			}
+		}
+	})
+
+	ids := []string{"player1", "player2", "player3", "player4"}
+	for i, id := range ids {
+		server.AddCommands(&SpawnCommand{world: server.World(), id: id, y: i, health: 100})
+	}
+	server.Tick()
+
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		player, _ := server.World().Player(id)
+		target, _ := server.World().Player(ids[(i+1)%len(ids)])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				server.Submit(player.ID(), &MoveCommand{player: player, direction: "right"})
+			}
+			server.Submit(player.ID(), &AttackCommand{palyer: player, target: target})
+		}()
+	}
+	wg.Wait()
+
+	player1, _ := server.World().Player("player1")
+	server.Submit("player1",
+		&MoveCommand{player: player1, direction: "rigth"},
+		&AttackCommand{palyer: player1, target: player1},
+	)
+	server.Submit("player2", &MoveCommand{player: player1, direction: "left"})
+	server.Submit("player2", &SpawnCommand{world: server.World(), id: "player5", health: 100})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server.OnAfterTick(func(stats TickStats) {
+		if stats.Pending == 0 {
+			cancel()
+		}
+	})
+	server.Run(ctx, 10*time.Millisecond)
+
+	for _, p := range server.World().Players() {
+		fmt.Println(p)
+	}
+}
+
+func runSchedule() {
+	clock := NewManualClock(time.Unix(0, 0))
+	server := NewGameServer(NewWorld(), WithClock(clock))
+	server.AddCommands(
+		&SpawnCommand{world: server.World(), id: "mage", health: 100},
+		&SpawnCommand{world: server.World(), id: "knight", x: 3, health: 100},
+	)
+	server.ProcessCommands()
+
+	mage, _ := server.World().Player("mage")
+	knight, _ := server.World().Player("knight")
+
+	server.Schedule("mage", &AttackCommand{palyer: mage, target: knight},
+		WithDelay(3*time.Second), WithCancelIf(CancelIfMoved(mage)))
+	server.Schedule("knight", &MoveCommand{player: knight, direction: "left"},
+		WithRepeat(2, 0))
+	second, _ := server.Schedule("mage", &AttackCommand{palyer: mage, target: knight},
+		WithDelay(5*time.Second), WithCancelIf(CancelIfMoved(mage)))
+
+	for i := 0; i < 4; i++ {
+		server.Tick()
+		clock.Advance(time.Second)
+	}
+	fmt.Println("after cast:", mage, knight)
+
+	server.Submit("mage", &MoveCommand{player: mage, direction: "up"})
+	for i := 0; i < 3; i++ {
+		server.Tick()
+		clock.Advance(time.Second)
+	}
+	fmt.Println("second cast cancelled:", second.Cancelled(), mage, knight)
+}
+
+func runWorld() {
+	world := NewWorld(
+		WithTiles(
+			".....",
+			"..#..",
+			".....",
+		),
+		WithAttackRange(4),
+	)
+	server := NewGameServer(world)
+	server.AddCommands(
+		&SpawnCommand{world: world, id: "archer", x: 0, y: 1, health: 100},
+		&SpawnCommand{world: world, id: "goblin", x: 3, y: 1, health: 100},
+		&SpawnCommand{world: world, id: "wolf", x: 1, y: 2, health: 100},
+	)
+	server.ProcessCommands()
+
+	archer, _ := world.Player("archer")
+	goblin, _ := world.Player("goblin")
+	wolf, _ := world.Player("wolf")
+
+	server.AddCommands(
+		&AttackCommand{palyer: archer, target: goblin},
+		&MoveCommand{player: archer, direction: "left"},
+		&MoveCommand{player: archer, direction: "up"},
+		&AttackCommand{palyer: archer, target: goblin},
+		&AttackCommand{palyer: archer, target: wolf},
+	)
+	results, _ := server.ProcessCommands()
+	for _, r := range results {
+		fmt.Println(r)
+	}
+	fmt.Println("near archer:", world.PlayersWithin(archer.x, archer.y, 2))
+}
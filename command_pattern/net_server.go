@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+const clientSendBuffer = 64
+
+var ErrPlayerTaken = errors.New("player is controlled by another connection")
+
+// NetServer is a TCP front end for a GameServer. It turns client frames
+// into commands submitted for the connection's player, and after every
+// tick sends each client the results of its commands and a delta of the
+// players that changed. Ticks are driven by GameServer.Run, not here.
+type NetServer struct {
+	game *GameServer
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	clients   map[*netClient]struct{}
+	owners    map[string]*netClient // player id -> the connection bound to it
+	last      map[string]PlayerState
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+type netClient struct {
+	conn net.Conn
+	send chan serverMessage
+
+	// guarded by NetServer.mu
+	dead    bool
+	player  string
+	fresh   bool
+	pending map[Command]uint64
+}
+
+func NewNetServer(game *GameServer) *NetServer {
+	s := &NetServer{
+		game:      game,
+		listeners: make(map[net.Listener]struct{}),
+		clients:   make(map[*netClient]struct{}),
+		owners:    make(map[string]*netClient),
+		last:      make(map[string]PlayerState),
+	}
+	game.OnBeforeTick(s.spawnPlayers)
+	game.OnAfterTick(s.broadcast)
+	return s
+}
+
+func (s *NetServer) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until l or the server is closed.
+func (s *NetServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			return err
+		}
+		c := &netClient{conn: conn, send: make(chan serverMessage, clientSendBuffer), pending: make(map[Command]uint64)}
+		s.mu.Lock()
+		s.clients[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(2)
+		go s.writeLoop(c)
+		go s.readLoop(c)
+	}
+}
+
+func (s *NetServer) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Close stops accepting connections and disconnects every client.
+func (s *NetServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	clients := make([]*netClient, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		s.drop(c)
+	}
+	s.wg.Wait()
+	return nil
+}
+
+func (s *NetServer) drop(c *netClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropLocked(c)
+}
+
+func (s *NetServer) dropLocked(c *netClient) {
+	if c.dead {
+		return
+	}
+	c.dead = true
+	delete(s.clients, c)
+	if s.owners[c.player] == c {
+		delete(s.owners, c.player)
+	}
+	c.conn.Close()
+	close(c.send)
+}
+
+// push queues msg for c without blocking. A client that cannot keep up
+// is disconnected rather than slowing down the tick. Callers hold s.mu.
+func (s *NetServer) push(c *netClient, msg serverMessage) {
+	if c.dead {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		s.dropLocked(c)
+	}
+}
+
+func (s *NetServer) writeLoop(c *netClient) {
+	defer s.wg.Done()
+	w := bufio.NewWriter(c.conn)
+	for msg := range c.send {
+		if err := writeFrame(w, msg); err != nil {
+			break
+		}
+		if len(c.send) == 0 && w.Flush() != nil {
+			break
+		}
+	}
+	s.drop(c)
+	for range c.send {
+	}
+}
+
+func (s *NetServer) readLoop(c *netClient) {
+	defer s.wg.Done()
+	defer s.drop(c)
+
+	r := bufio.NewReader(c.conn)
+	for {
+		var msg clientMessage
+		if err := readFrame(r, &msg); err != nil {
+			return
+		}
+		if err := s.handle(c, msg); err != nil {
+			s.mu.Lock()
+			s.push(c, serverMessage{Type: msgError, Seq: msg.Seq, Error: err.Error()})
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *NetServer) handle(c *netClient, msg clientMessage) error {
+	s.mu.Lock()
+	player := c.player
+	s.mu.Unlock()
+
+	if msg.Type == msgHello {
+		return s.hello(c, msg.Player)
+	}
+	if player == "" {
+		return errors.New("hello required")
+	}
+
+	world := s.game.World()
+	self, ok := world.Player(player)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownPlayer, player)
+	}
+
+	var cmd Command
+	switch msg.Type {
+	case msgMove:
+		cmd = &MoveCommand{player: self, direction: msg.Direction}
+	case msgAttack:
+		target, ok := world.Player(msg.Target)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownPlayer, msg.Target)
+		}
+		cmd = &AttackCommand{palyer: self, target: target}
+	default:
+		return fmt.Errorf("unknown message type %q", msg.Type)
+	}
+
+	s.mu.Lock()
+	c.pending[cmd] = msg.Seq
+	s.mu.Unlock()
+	s.game.Submit(player, cmd)
+	return nil
+}
+
+// hello binds the connection to a player, which is spawned on the next
+// tick when it is not in the world yet. A player is bound to at most one
+// open connection and is released when that connection closes. There is
+// no authentication beyond that: this front end is meant for local play
+// and load tests.
+func (s *NetServer) hello(c *netClient, player string) error {
+	if player == "" {
+		return ErrInvalidPlayerID
+	}
+	s.mu.Lock()
+	if c.player != "" {
+		s.mu.Unlock()
+		return fmt.Errorf("already playing as %q", c.player)
+	}
+	if _, ok := s.owners[player]; ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrPlayerTaken, player)
+	}
+	s.owners[player] = c
+	c.player = player
+	c.fresh = true
+	s.push(c, serverMessage{Type: msgWelcome, Player: player})
+	s.mu.Unlock()
+	return nil
+}
+
+// spawnPlayers runs as a before tick hook and spawns the players of
+// connected clients that are not in the world, each on its own tile.
+func (s *NetServer) spawnPlayers(uint64) {
+	world := s.game.World()
+	taken := make(map[point]bool)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.clients {
+		if c.player == "" {
+			continue
+		}
+		if _, ok := world.Player(c.player); ok {
+			continue
+		}
+		x, y, ok := freePosition(world, taken)
+		if !ok {
+			continue
+		}
+		taken[point{x, y}] = true
+		s.game.AddCommands(&SpawnCommand{world: world, id: c.player, x: x, y: y, health: 100})
+	}
+}
+
+// freePosition scans row by row for a tile a new player can enter.
+func freePosition(w *World, taken map[point]bool) (int, int, bool) {
+	width := w.width
+	if width <= 0 {
+		width = 64
+	}
+	for y := 0; w.height <= 0 || y < w.height; y++ {
+		for x := 0; x < width; x++ {
+			if !taken[point{x, y}] && w.CanEnter(nil, x, y) == nil {
+				return x, y, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// broadcast runs as an after tick hook, when no command is running.
+func (s *NetServer) broadcast(stats TickStats) {
+	players := s.game.World().Players()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []PlayerState
+	current := make(map[string]PlayerState, len(players))
+	for _, p := range players {
+		state := stateOf(p)
+		current[p.id] = state
+		if last, ok := s.last[p.id]; !ok || last != state {
+			changed = append(changed, state)
+		}
+	}
+	var removed []string
+	for id := range s.last {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	s.last = current
+
+	var snapshot []PlayerState
+	for c := range s.clients {
+		for _, r := range stats.Results {
+			if r.Issuer != c.player {
+				continue
+			}
+			seq, ok := c.pending[r.Command]
+			if !ok {
+				continue
+			}
+			delete(c.pending, r.Command)
+			result := serverMessage{Type: msgResult, Tick: stats.Tick, Seq: seq, Status: r.Status.String()}
+			if r.Err != nil {
+				result.Error = r.Err.Error()
+			}
+			s.push(c, result)
+		}
+
+		switch {
+		case c.fresh && c.player != "":
+			if snapshot == nil {
+				snapshot = make([]PlayerState, 0, len(players))
+				for _, p := range players {
+					snapshot = append(snapshot, current[p.id])
+				}
+			}
+			c.fresh = false
+			s.push(c, serverMessage{Type: msgSnapshot, Tick: stats.Tick, Players: snapshot})
+		case len(changed) > 0 || len(removed) > 0:
+			s.push(c, serverMessage{Type: msgDelta, Tick: stats.Tick, Players: changed, Removed: removed})
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialTest(t *testing.T, addr string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *testClient) send(msg clientMessage) {
+	c.t.Helper()
+	if err := writeFrame(c.conn, msg); err != nil {
+		c.t.Fatalf("send: %v", err)
+	}
+}
+
+// expect reads frames until one of type typ arrives.
+func (c *testClient) expect(typ string) serverMessage {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg serverMessage
+		if err := readFrame(c.r, &msg); err != nil {
+			c.t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		if msg.Type == typ {
+			return msg
+		}
+	}
+}
+
+func startNetServer(t *testing.T, game *GameServer) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	front := NewNetServer(game)
+	go front.Serve(l)
+	t.Cleanup(func() { front.Close() })
+	return l.Addr().String()
+}
+
+func TestNetServer_HelloOwnership(t *testing.T) {
+	game := NewGameServer(NewWorld(WithBounds(8, 8)), WithPolicy(OwnPlayerOnly))
+	addr := startNetServer(t, game)
+
+	first := dialTest(t, addr)
+	first.send(clientMessage{Type: msgHello, Player: "p1"})
+	if msg := first.expect(msgWelcome); msg.Player != "p1" {
+		t.Fatalf("expected welcome for p1, got %+v", msg)
+	}
+
+	tests := []struct {
+		name   string
+		player string
+		want   string
+	}{
+		{"taken", "p1", ErrPlayerTaken.Error()},
+		{"empty", "", ErrInvalidPlayerID.Error()},
+	}
+	second := dialTest(t, addr)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			second.send(clientMessage{Type: msgHello, Seq: 1, Player: tt.player})
+			if msg := second.expect(msgError); !strings.Contains(msg.Error, tt.want) {
+				t.Fatalf("expected %q, got %+v", tt.want, msg)
+			}
+		})
+	}
+
+	// The first connection still controls p1 after the rejected hellos.
+	game.Tick()
+	first.expect(msgSnapshot)
+	first.send(clientMessage{Type: msgMove, Seq: 7, Direction: "up"})
+	deadline := time.Now().Add(5 * time.Second)
+	for game.Pending() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	game.Tick()
+	if msg := first.expect(msgResult); msg.Seq != 7 || msg.Status != StatusAccepted.String() {
+		t.Fatalf("expected move accepted, got %+v", msg)
+	}
+
+	// Closing the owner releases p1 for other connections.
+	first.conn.Close()
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		second.send(clientMessage{Type: msgHello, Player: "p1"})
+		var msg serverMessage
+		second.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := readFrame(second.r, &msg); err != nil {
+			t.Fatalf("waiting for welcome: %v", err)
+		}
+		if msg.Type == msgWelcome {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("p1 was not released, last message %+v", msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNetServer_SnapshotAndDelta(t *testing.T) {
+	game := NewGameServer(NewWorld(WithBounds(8, 8)), WithPolicy(OwnPlayerOnly))
+	addr := startNetServer(t, game)
+
+	clients := map[string]*testClient{}
+	for _, id := range []string{"p1", "p2"} {
+		c := dialTest(t, addr)
+		c.send(clientMessage{Type: msgHello, Player: id})
+		c.expect(msgWelcome)
+		clients[id] = c
+	}
+
+	game.Tick()
+	for id, c := range clients {
+		if msg := c.expect(msgSnapshot); len(msg.Players) != 2 {
+			t.Fatalf("%s: expected a snapshot of 2 players, got %+v", id, msg)
+		}
+	}
+
+	clients["p1"].send(clientMessage{Type: msgMove, Seq: 1, Direction: "up"})
+	deadline := time.Now().Add(5 * time.Second)
+	for game.Pending() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	game.Tick()
+	for id, c := range clients {
+		msg := c.expect(msgDelta)
+		if len(msg.Players) != 1 || msg.Players[0].ID != "p1" || msg.Players[0].Y != 1 {
+			t.Fatalf("%s: expected a delta with p1 only, got %+v", id, msg)
+		}
+	}
+}
+
+func TestReadFrame_TooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	var msg clientMessage
+	if err := readFrame(&buf, &msg); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected ErrFrameTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+type serverOption struct {
+	historyDepth int
+	tickBudget   int
+	policy       Policy
+	clock        Clock
+}
+
+type Option struct {
+	apply func(*serverOption)
+}
+
+func defaultServerOptions() *serverOption {
+	return &serverOption{
+		historyDepth: 100,
+		tickBudget:   0,
+		policy:       AllowAll,
+		clock:        SystemClock{},
+	}
+}
+
+// WithHistoryDepth limits how many steps can be undone, 0 means unlimited.
+func WithHistoryDepth(depth int) *Option {
+	return &Option{
+		apply: func(option *serverOption) {
+			option.historyDepth = depth
+		},
+	}
+}
+
+// WithTickBudget limits how many commands of one player run per tick,
+// 0 means unlimited. Commands over the budget wait for the next tick.
+func WithTickBudget(budget int) *Option {
+	return &Option{
+		apply: func(option *serverOption) {
+			option.tickBudget = budget
+		},
+	}
+}
+
+// WithPolicy sets the policy that authorizes submitted commands.
+func WithPolicy(policy Policy) *Option {
+	return &Option{
+		apply: func(option *serverOption) {
+			option.policy = policy
+		},
+	}
+}
+
+// WithClock sets the clock that drives scheduled commands.
+func WithClock(clock Clock) *Option {
+	return &Option{
+		apply: func(option *serverOption) {
+			option.clock = clock
+		},
+	}
+}
@@ -1,22 +1,68 @@
 package main
 
+import "fmt"
+
 type Player struct {
+	id     string
 	x      int
 	y      int
 	health int
+	world  *World
+
+	// version is the world version of the last tile the player took.
+	version uint64
+}
+
+func (p *Player) ID() string {
+	return p.id
 }
 
-func (p *Player) Move(direction string) {
+func (p *Player) String() string {
+	return fmt.Sprintf("&{%s %d %d %d}", p.id, p.x, p.y, p.health)
+}
+
+// Position reads the position under the world's lock, so it can be
+// called while a tick moves the player.
+func (p *Player) Position() (x, y int) {
+	if p.world != nil {
+		p.world.mu.RLock()
+		defer p.world.mu.RUnlock()
+	}
+	return p.x, p.y
+}
+
+// Move returns why the player could not move, e.g. ErrOccupied, and
+// leaves it where it was in that case.
+func (p *Player) Move(direction string) error {
+	x, y := destination(p.x, p.y, direction)
+	if p.world != nil {
+		return p.world.move(p, x, y)
+	}
+	p.x, p.y = x, y
+	return nil
+}
+
+func destination(x, y int, direction string) (int, int) {
 	switch direction {
 	case "up":
-		p.y++
+		y++
 	case "down":
-		p.y--
+		y--
 	case "left":
-		p.x--
+		x--
 	case "right":
-		p.x++
+		x++
+	}
+	return x, y
+}
+
+// setPosition moves p without any checks.
+func (p *Player) setPosition(x, y int) {
+	if p.world != nil {
+		p.world.place(p, x, y)
+		return
 	}
+	p.x, p.y = x, y
 }
 
 func (p *Player) Attack(target *Player) {
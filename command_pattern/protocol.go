@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Every frame is a 4 byte big-endian length followed by a JSON message.
+const maxFrameSize = 1 << 20
+
+var ErrFrameTooLarge = errors.New("frame too large")
+
+const (
+	msgHello    = "hello"
+	msgMove     = "move"
+	msgAttack   = "attack"
+	msgWelcome  = "welcome"
+	msgSnapshot = "snapshot"
+	msgDelta    = "delta"
+	msgResult   = "result"
+	msgError    = "error"
+)
+
+// clientMessage is sent by clients. hello must come first and names the
+// player the connection controls; Seq is echoed back in the result.
+type clientMessage struct {
+	Type      string `json:"type"`
+	Seq       uint64 `json:"seq,omitempty"`
+	Player    string `json:"player,omitempty"`
+	Direction string `json:"direction,omitempty"`
+	Target    string `json:"target,omitempty"`
+}
+
+type PlayerState struct {
+	ID     string `json:"id"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Health int    `json:"health"`
+}
+
+func stateOf(p *Player) PlayerState {
+	return PlayerState{ID: p.id, X: p.x, Y: p.y, Health: p.health}
+}
+
+// serverMessage is sent by the server. A snapshot carries every player,
+// a delta only the players that changed since the previous tick.
+type serverMessage struct {
+	Type    string        `json:"type"`
+	Tick    uint64        `json:"tick,omitempty"`
+	Player  string        `json:"player,omitempty"`
+	Players []PlayerState `json:"players,omitempty"`
+	Removed []string      `json:"removed,omitempty"`
+	Seq     uint64        `json:"seq,omitempty"`
+	Status  string        `json:"status,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+func writeFrame(w io.Writer, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err = w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader, v interface{}) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n > maxFrameSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
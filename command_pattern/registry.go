@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command type")
+	ErrUnknownPlayer  = errors.New("unknown player")
+)
+
+// FieldWriter and FieldReader let a CommandCodec describe its fields once
+// for every log format. Binary logs ignore the names and rely on the
+// order, so Decode must read fields in the order Encode wrote them.
+type FieldWriter interface {
+	String(name, v string)
+	Int(name string, v int)
+}
+
+type FieldReader interface {
+	String(name string) string
+	Int(name string) int
+	Err() error
+}
+
+// CommandCodec converts one command type to and from log records.
+// Decode receives the version the record was written with, so older
+// logs can still be replayed after the command changes.
+type CommandCodec struct {
+	Name    string
+	Version int
+	Encode  func(c Command, w FieldWriter)
+	Decode  func(world *World, version int, r FieldReader) (Command, error)
+}
+
+type CommandRegistry struct {
+	byName map[string]*CommandCodec
+	byType map[reflect.Type]*CommandCodec
+}
+
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		byName: make(map[string]*CommandCodec),
+		byType: make(map[reflect.Type]*CommandCodec),
+	}
+}
+
+// Register binds codec to the dynamic type of sample.
+func (r *CommandRegistry) Register(sample Command, codec CommandCodec) {
+	c := &codec
+	r.byName[c.Name] = c
+	r.byType[reflect.TypeOf(sample)] = c
+}
+
+func (r *CommandRegistry) codecOf(c Command) (*CommandCodec, error) {
+	codec, ok := r.byType[reflect.TypeOf(c)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnknownCommand, c)
+	}
+	return codec, nil
+}
+
+func (r *CommandRegistry) lookup(name string) (*CommandCodec, error) {
+	codec, ok := r.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCommand, name)
+	}
+	return codec, nil
+}
+
+func lookupPlayer(world *World, id string) (*Player, error) {
+	p, ok := world.Player(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPlayer, id)
+	}
+	return p, nil
+}
+
+type undoMarker struct{}
+
+func (undoMarker) Execute() error { return nil }
+
+type redoMarker struct{}
+
+func (redoMarker) Execute() error { return nil }
+
+// A MacroCommand is logged as its children between a macroBegin and a
+// macroEnd record, so replay can group them into one undo step again.
+type macroBegin struct{}
+
+func (macroBegin) Execute() error { return nil }
+
+type macroEnd struct{}
+
+func (macroEnd) Execute() error { return nil }
+
+// DefaultCommandRegistry knows the built-in game commands and the
+// markers written by GameServer.
+func DefaultCommandRegistry() *CommandRegistry {
+	r := NewCommandRegistry()
+
+	r.Register(&SpawnCommand{}, CommandCodec{
+		Name:    "spawn",
+		Version: 1,
+		Encode: func(c Command, w FieldWriter) {
+			s := c.(*SpawnCommand)
+			w.String("player", s.id)
+			w.Int("x", s.x)
+			w.Int("y", s.y)
+			w.Int("health", s.health)
+		},
+		Decode: func(world *World, _ int, r FieldReader) (Command, error) {
+			c := &SpawnCommand{world: world, id: r.String("player"), x: r.Int("x"), y: r.Int("y"), health: r.Int("health")}
+			return c, r.Err()
+		},
+	})
+
+	r.Register(&MoveCommand{}, CommandCodec{
+		Name:    "move",
+		Version: 1,
+		Encode: func(c Command, w FieldWriter) {
+			m := c.(*MoveCommand)
+			w.String("player", m.player.id)
+			w.String("direction", m.direction)
+		},
+		Decode: func(world *World, _ int, r FieldReader) (Command, error) {
+			id, direction := r.String("player"), r.String("direction")
+			if err := r.Err(); err != nil {
+				return nil, err
+			}
+			p, err := lookupPlayer(world, id)
+			if err != nil {
+				return nil, err
+			}
+			return &MoveCommand{player: p, direction: direction}, nil
+		},
+	})
+
+	r.Register(&AttackCommand{}, CommandCodec{
+		Name:    "attack",
+		Version: 1,
+		Encode: func(c Command, w FieldWriter) {
+			a := c.(*AttackCommand)
+			w.String("player", a.palyer.id)
+			w.String("target", a.target.id)
+		},
+		Decode: func(world *World, _ int, r FieldReader) (Command, error) {
+			id, targetID := r.String("player"), r.String("target")
+			if err := r.Err(); err != nil {
+				return nil, err
+			}
+			p, err := lookupPlayer(world, id)
+			if err != nil {
+				return nil, err
+			}
+			target, err := lookupPlayer(world, targetID)
+			if err != nil {
+				return nil, err
+			}
+			return &AttackCommand{palyer: p, target: target}, nil
+		},
+	})
+
+	r.Register(undoMarker{}, CommandCodec{
+		Name:    "undo",
+		Version: 1,
+		Encode:  func(Command, FieldWriter) {},
+		Decode:  func(*World, int, FieldReader) (Command, error) { return undoMarker{}, nil },
+	})
+	r.Register(redoMarker{}, CommandCodec{
+		Name:    "redo",
+		Version: 1,
+		Encode:  func(Command, FieldWriter) {},
+		Decode:  func(*World, int, FieldReader) (Command, error) { return redoMarker{}, nil },
+	})
+
+	r.Register(macroBegin{}, CommandCodec{
+		Name:    "macro_begin",
+		Version: 1,
+		Encode:  func(Command, FieldWriter) {},
+		Decode:  func(*World, int, FieldReader) (Command, error) { return macroBegin{}, nil },
+	})
+	r.Register(macroEnd{}, CommandCodec{
+		Name:    "macro_end",
+		Version: 1,
+		Encode:  func(Command, FieldWriter) {},
+		Decode:  func(*World, int, FieldReader) (Command, error) { return macroEnd{}, nil },
+	})
+
+	return r
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotCloneable rejects repeating a command that cannot be cloned,
+// since every run would overwrite the undo state of the one before.
+var ErrNotCloneable = errors.New("repeated command is not cloneable")
+
+// CancelToken cancels every scheduled command it was given to. It can
+// be shared by several schedules.
+type CancelToken struct {
+	once sync.Once
+	done chan struct{}
+}
+
+func NewCancelToken() *CancelToken {
+	return &CancelToken{done: make(chan struct{})}
+}
+
+func (t *CancelToken) Cancel() {
+	t.once.Do(func() { close(t.done) })
+}
+
+func (t *CancelToken) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *CancelToken) Cancelled() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+type scheduleOption struct {
+	delay    time.Duration
+	repeat   int
+	interval time.Duration
+	token    *CancelToken
+	cancelIf func() bool
+}
+
+type ScheduleOption struct {
+	apply func(*scheduleOption)
+}
+
+// WithDelay postpones the first run by d.
+func WithDelay(d time.Duration) *ScheduleOption {
+	return &ScheduleOption{
+		apply: func(option *scheduleOption) {
+			option.delay = d
+		},
+	}
+}
+
+// WithRepeat runs the command times times in total, interval apart.
+// times < 0 repeats until cancelled, and interval 0 means once per Poll,
+// which for a GameServer is once per tick.
+func WithRepeat(times int, interval time.Duration) *ScheduleOption {
+	return &ScheduleOption{
+		apply: func(option *scheduleOption) {
+			option.repeat = times
+			option.interval = interval
+		},
+	}
+}
+
+// WithCancelToken uses token instead of a new one.
+func WithCancelToken(token *CancelToken) *ScheduleOption {
+	return &ScheduleOption{
+		apply: func(option *scheduleOption) {
+			option.token = token
+		},
+	}
+}
+
+// WithCancelIf cancels the schedule when cond reports true at the time
+// the command is due.
+func WithCancelIf(cond func() bool) *ScheduleOption {
+	return &ScheduleOption{
+		apply: func(option *scheduleOption) {
+			option.cancelIf = cond
+		},
+	}
+}
+
+// CancelIfMoved is a WithCancelIf condition for "cancel if the caster
+// moves", comparing against the position p has now.
+func CancelIfMoved(p *Player) func() bool {
+	x, y := p.Position()
+	return func() bool {
+		nx, ny := p.Position()
+		return nx != x || ny != y
+	}
+}
+
+// Cloneable commands get a fresh copy for every repeated run, so the
+// undo state of one run is not overwritten by the next.
+type Cloneable interface {
+	Clone() Command
+}
+
+func (c *MoveCommand) Clone() Command {
+	return &MoveCommand{player: c.player, direction: c.direction}
+}
+
+func (c *AttackCommand) Clone() Command {
+	return &AttackCommand{palyer: c.palyer, target: c.target}
+}
+
+func (c *SpawnCommand) Clone() Command {
+	return &SpawnCommand{world: c.world, id: c.id, x: c.x, y: c.y, health: c.health}
+}
+
+// cloneable reports whether c, and every child of a macro, is Cloneable.
+func cloneable(c Command) bool {
+	if m, ok := c.(*MacroCommand); ok {
+		for _, child := range m.commands {
+			if !cloneable(child) {
+				return false
+			}
+		}
+		return true
+	}
+	_, ok := c.(Cloneable)
+	return ok
+}
+
+func (m *MacroCommand) Clone() Command {
+	clone := NewMacroCommand()
+	for _, c := range m.commands {
+		if cc, ok := c.(Cloneable); ok {
+			c = cc.Clone().(UndoableCommand)
+		}
+		clone.Add(c)
+	}
+	return clone
+}
+
+type scheduled struct {
+	seq     uint64
+	due     time.Time
+	issuer  string
+	command Command
+	left    int
+	opts    *scheduleOption
+}
+
+type scheduleHeap []*scheduled
+
+func (h scheduleHeap) Len() int { return len(h) }
+func (h scheduleHeap) Less(i, j int) bool {
+	if !h[i].due.Equal(h[j].due) {
+		return h[i].due.Before(h[j].due)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h scheduleHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scheduleHeap) Push(x interface{}) { *h = append(*h, x.(*scheduled)) }
+func (h *scheduleHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// Scheduler holds commands until they are due and then hands them to
+// submit. It does not run on its own: Poll decides when time is checked.
+type Scheduler struct {
+	mu     sync.Mutex
+	clock  Clock
+	submit func(issuer string, commands ...Command)
+	items  scheduleHeap
+	seq    uint64
+}
+
+func NewScheduler(clock Clock, submit func(issuer string, commands ...Command)) *Scheduler {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Scheduler{clock: clock, submit: submit}
+}
+
+// Schedule holds c until it is due. A command that runs more than once
+// must be Cloneable, otherwise ErrNotCloneable is returned.
+func (s *Scheduler) Schedule(issuer string, c Command, options ...*ScheduleOption) (*CancelToken, error) {
+	opts := &scheduleOption{repeat: 1}
+	for _, option := range options {
+		option.apply(opts)
+	}
+	if opts.repeat != 1 && !cloneable(c) {
+		return nil, fmt.Errorf("%w: %T", ErrNotCloneable, c)
+	}
+	if opts.token == nil {
+		opts.token = NewCancelToken()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	heap.Push(&s.items, &scheduled{
+		seq:     s.seq,
+		due:     s.clock.Now().Add(opts.delay),
+		issuer:  issuer,
+		command: c,
+		left:    opts.repeat,
+		opts:    opts,
+	})
+	return opts.token, nil
+}
+
+// Poll submits every command that is due and returns how many were
+// submitted. A repeating command fires at most once per Poll.
+func (s *Scheduler) Poll() int {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	var due []*scheduled
+	for len(s.items) > 0 && !s.items[0].due.After(now) {
+		due = append(due, heap.Pop(&s.items).(*scheduled))
+	}
+	s.mu.Unlock()
+
+	n := 0
+	for _, item := range due {
+		if item.opts.cancelIf != nil && item.opts.cancelIf() {
+			item.opts.token.Cancel()
+		}
+		if item.opts.token.Cancelled() {
+			continue
+		}
+
+		c := item.command
+		if item.left != 1 {
+			if cc, ok := c.(Cloneable); ok {
+				c = cc.Clone()
+			}
+		}
+		s.submit(item.issuer, c)
+		n++
+
+		if item.left > 0 {
+			item.left--
+		}
+		if item.left == 0 {
+			continue
+		}
+		item.due = item.due.Add(item.opts.interval)
+		if !item.due.After(now) {
+			item.due = now.Add(item.opts.interval)
+		}
+		s.mu.Lock()
+		heap.Push(&s.items, item)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Len returns the number of commands waiting, including cancelled ones
+// that have not been polled yet.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	tests := []struct {
+		name    string
+		options func(p *Player) []*ScheduleOption
+		cancel  func(token *CancelToken, p *Player)
+		steps   []time.Duration // clock advance before each poll
+		want    []int           // submitted per poll
+	}{
+		{
+			name:  "immediate",
+			steps: []time.Duration{0, time.Second},
+			want:  []int{1, 0},
+		},
+		{
+			name:    "delay",
+			options: func(*Player) []*ScheduleOption { return []*ScheduleOption{WithDelay(2 * time.Second)} },
+			steps:   []time.Duration{time.Second, time.Second, time.Second},
+			want:    []int{0, 1, 0},
+		},
+		{
+			name: "repeat",
+			options: func(*Player) []*ScheduleOption {
+				return []*ScheduleOption{WithRepeat(3, time.Second)}
+			},
+			steps: []time.Duration{0, 500 * time.Millisecond, 500 * time.Millisecond, 5 * time.Second, time.Second},
+			want:  []int{1, 0, 1, 1, 0},
+		},
+		{
+			name:    "repeat every poll",
+			options: func(*Player) []*ScheduleOption { return []*ScheduleOption{WithRepeat(-1, 0)} },
+			steps:   []time.Duration{0, 0, 0},
+			want:    []int{1, 1, 1},
+		},
+		{
+			name:    "cancel token",
+			options: func(*Player) []*ScheduleOption { return []*ScheduleOption{WithRepeat(-1, time.Second)} },
+			cancel:  func(token *CancelToken, _ *Player) { token.Cancel() },
+			steps:   []time.Duration{0, time.Second},
+			want:    []int{1, 0},
+		},
+		{
+			name: "cancel if moved",
+			options: func(p *Player) []*ScheduleOption {
+				return []*ScheduleOption{WithDelay(time.Second), WithCancelIf(CancelIfMoved(p))}
+			},
+			cancel: func(_ *CancelToken, p *Player) { p.Move("up") },
+			steps:  []time.Duration{0, time.Second},
+			want:   []int{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(time.Unix(0, 0))
+			var submitted []Command
+			s := NewScheduler(clock, func(_ string, commands ...Command) { submitted = append(submitted, commands...) })
+			p := &Player{id: "p"}
+			var options []*ScheduleOption
+			if tt.options != nil {
+				options = tt.options(p)
+			}
+			token, err := s.Schedule("p", &MoveCommand{player: p, direction: "right"}, options...)
+			if err != nil {
+				t.Fatalf("schedule: %v", err)
+			}
+
+			for i, step := range tt.steps {
+				clock.Advance(step)
+				if n := s.Poll(); n != tt.want[i] {
+					t.Fatalf("poll %d: expected %d submitted, got %d", i, tt.want[i], n)
+				}
+				if i == 0 && tt.cancel != nil {
+					tt.cancel(token, p)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduler_RepeatClonesCommand(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	var submitted []Command
+	s := NewScheduler(clock, func(_ string, commands ...Command) { submitted = append(submitted, commands...) })
+	s.Schedule("p", &MoveCommand{player: &Player{id: "p"}, direction: "up"}, WithRepeat(2, 0))
+	s.Poll()
+	s.Poll()
+	if len(submitted) != 2 || submitted[0] == submitted[1] {
+		t.Fatalf("expected two distinct commands, got %v", submitted)
+	}
+}
+
+func TestGameServer_Schedule(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	s := NewGameServer(NewWorld(), WithClock(clock))
+	spawnPlayers(t, s, map[string][2]int{"mage": {0, 0}, "knight": {1, 0}})
+	mage, knight := mustPlayer(t, s.World(), "mage"), mustPlayer(t, s.World(), "knight")
+
+	s.Schedule("mage", &AttackCommand{palyer: mage, target: knight}, WithDelay(time.Second), WithCancelIf(CancelIfMoved(mage)))
+	s.Tick()
+	if knight.health != 100 {
+		t.Fatalf("expected the cast to wait for its delay, got %v", knight)
+	}
+	clock.Advance(time.Second)
+	s.Tick()
+	if knight.health != 90 {
+		t.Fatalf("expected the cast to land once due, got %v", knight)
+	}
+}
+
+// nudge is undoable but not Cloneable.
+type nudge struct {
+	teleport
+}
+
+func (c *nudge) Undo() {}
+
+func TestScheduler_RepeatRequiresCloneable(t *testing.T) {
+	s := NewScheduler(NewManualClock(time.Unix(0, 0)), func(string, ...Command) {})
+	p := &Player{id: "p"}
+	tests := []struct {
+		name    string
+		command Command
+		repeat  int
+		wantErr error
+	}{
+		{"once", &teleport{player: p}, 1, nil},
+		{"repeat", &teleport{player: p}, 2, ErrNotCloneable},
+		{"repeat forever", &teleport{player: p}, -1, ErrNotCloneable},
+		{"repeat cloneable", &MoveCommand{player: p, direction: "up"}, 2, nil},
+		{"macro", NewMacroCommand(&MoveCommand{player: p, direction: "up"}, &nudge{teleport{player: p}}), 2, ErrNotCloneable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := s.Schedule("p", tt.command, WithRepeat(tt.repeat, time.Second))
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (token != nil) {
+				t.Fatalf("expected %v, got %v, %v", tt.wantErr, token, err)
+			}
+		})
+	}
+}
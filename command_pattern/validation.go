@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidDirection = errors.New("invalid direction")
+	ErrInvalidPlayerID  = errors.New("invalid player id")
+	ErrPlayerExists     = errors.New("player already exists")
+	ErrPlayerDead       = errors.New("player is dead")
+	ErrTargetDead       = errors.New("target is dead")
+	ErrSelfAttack       = errors.New("player cannot attack itself")
+	ErrUnauthorized     = errors.New("not authorized")
+)
+
+// ValidatableCommand checks a command against the current world before
+// it runs. Errors should wrap one of the Err* values above.
+type ValidatableCommand interface {
+	Command
+	Validate(world *World) error
+}
+
+func validDirection(direction string) bool {
+	switch direction {
+	case "up", "down", "left", "right":
+		return true
+	}
+	return false
+}
+
+// checkAlive makes sure p is still in the world and has health left.
+func checkAlive(world *World, p *Player, dead error) error {
+	if current, ok := world.Player(p.id); !ok || current != p {
+		return fmt.Errorf("%w: %q", ErrUnknownPlayer, p.id)
+	}
+	if p.health <= 0 {
+		return fmt.Errorf("%w: %q", dead, p.id)
+	}
+	return nil
+}
+
+func (c *MoveCommand) Validate(world *World) error {
+	if !validDirection(c.direction) {
+		return fmt.Errorf("%w: %q", ErrInvalidDirection, c.direction)
+	}
+	if err := checkAlive(world, c.player, ErrPlayerDead); err != nil {
+		return err
+	}
+	x, y := destination(c.player.x, c.player.y, c.direction)
+	return world.CanEnter(c.player, x, y)
+}
+
+func (c *AttackCommand) Validate(world *World) error {
+	if c.palyer == c.target || c.palyer.id == c.target.id {
+		return fmt.Errorf("%w: %q", ErrSelfAttack, c.palyer.id)
+	}
+	if err := checkAlive(world, c.palyer, ErrPlayerDead); err != nil {
+		return err
+	}
+	if err := checkAlive(world, c.target, ErrTargetDead); err != nil {
+		return err
+	}
+	return world.CanAttack(c.palyer, c.target)
+}
+
+func (c *SpawnCommand) Validate(world *World) error {
+	if c.id == "" {
+		return ErrInvalidPlayerID
+	}
+	if _, ok := world.Player(c.id); ok {
+		return fmt.Errorf("%w: %q", ErrPlayerExists, c.id)
+	}
+	return world.CanEnter(nil, c.x, c.y)
+}
+
+// Validate checks the children one by one against the current world,
+// so a child cannot rely on the effects of an earlier one.
+func (m *MacroCommand) Validate(world *World) error {
+	for i, c := range m.commands {
+		v, ok := c.(ValidatableCommand)
+		if !ok {
+			continue
+		}
+		if err := v.Validate(world); err != nil {
+			return fmt.Errorf("macro step %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Policy decides whether issuer may submit c. The issuer "" is the
+// server itself.
+type Policy interface {
+	Authorize(issuer string, c Command) error
+}
+
+type PolicyFunc func(issuer string, c Command) error
+
+func (f PolicyFunc) Authorize(issuer string, c Command) error {
+	return f(issuer, c)
+}
+
+// AllowAll accepts every command.
+var AllowAll Policy = PolicyFunc(func(string, Command) error { return nil })
+
+// OwnPlayerOnly lets players issue commands only for themselves, and
+// leaves spawning and commands without players to the server.
+var OwnPlayerOnly Policy = PolicyFunc(func(issuer string, c Command) error {
+	if issuer == "" {
+		return nil
+	}
+	if _, ok := c.(*SpawnCommand); ok {
+		return fmt.Errorf("%w: %q cannot spawn players", ErrUnauthorized, issuer)
+	}
+	players := playersOf(c)
+	if len(players) == 0 || players[0] != issuer {
+		return fmt.Errorf("%w: %q cannot act for another player", ErrUnauthorized, issuer)
+	}
+	return nil
+})
+
+type Status int
+
+const (
+	StatusAccepted Status = iota
+	StatusRejected
+	StatusFailed
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusAccepted:
+		return "accepted"
+	case StatusRejected:
+		return "rejected"
+	case StatusFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// CommandResult reports what happened to one command. Err is the
+// rejection reason or the failure, and nil for accepted commands.
+type CommandResult struct {
+	Tick    uint64
+	Issuer  string
+	Command Command
+	Status  Status
+	Err     error
+}
+
+func (r CommandResult) String() string {
+	if r.Err == nil {
+		return fmt.Sprintf("tick %d: %T %s", r.Tick, r.Command, r.Status)
+	}
+	return fmt.Sprintf("tick %d: %T %s: %v", r.Tick, r.Command, r.Status, r.Err)
+}
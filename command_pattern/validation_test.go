@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	world := NewWorld(WithTiles(
+		"...",
+		".#.",
+		"...",
+	))
+	a := &Player{id: "a", x: 0, y: 0, health: 100}
+	b := &Player{id: "b", x: 1, y: 0, health: 100}
+	dead := &Player{id: "dead", x: 0, y: 2, health: 0}
+	ghost := &Player{id: "ghost", health: 100}
+	for _, p := range []*Player{a, b, dead} {
+		world.AddPlayer(p)
+	}
+
+	tests := []struct {
+		name    string
+		command ValidatableCommand
+		want    error
+	}{
+		{"move", &MoveCommand{player: a, direction: "up"}, nil},
+		{"bad direction", &MoveCommand{player: a, direction: "rigth"}, ErrInvalidDirection},
+		{"out of bounds", &MoveCommand{player: a, direction: "left"}, ErrOutOfBounds},
+		{"blocked", &MoveCommand{player: b, direction: "up"}, ErrBlocked},
+		{"occupied", &MoveCommand{player: a, direction: "right"}, ErrOccupied},
+		{"dead mover", &MoveCommand{player: dead, direction: "right"}, ErrPlayerDead},
+		{"unknown player", &MoveCommand{player: ghost, direction: "up"}, ErrUnknownPlayer},
+		{"attack", &AttackCommand{palyer: a, target: b}, nil},
+		{"self attack", &AttackCommand{palyer: a, target: a}, ErrSelfAttack},
+		{"dead target", &AttackCommand{palyer: a, target: dead}, ErrTargetDead},
+		{"spawn", &SpawnCommand{world: world, id: "c", x: 2, y: 0}, nil},
+		{"spawn without id", &SpawnCommand{world: world, x: 2, y: 0}, ErrInvalidPlayerID},
+		{"spawn existing", &SpawnCommand{world: world, id: "a", x: 2, y: 0}, ErrPlayerExists},
+		{"spawn on wall", &SpawnCommand{world: world, id: "c", x: 1, y: 1}, ErrBlocked},
+		{"spawn on player", &SpawnCommand{world: world, id: "c", x: 1, y: 0}, ErrOccupied},
+		{"macro", NewMacroCommand(&MoveCommand{player: a, direction: "up"}, &AttackCommand{palyer: a, target: a}), ErrSelfAttack},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.command.Validate(world)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestOwnPlayerOnly(t *testing.T) {
+	a, b := &Player{id: "a"}, &Player{id: "b"}
+
+	tests := []struct {
+		name    string
+		issuer  string
+		command Command
+		allowed bool
+	}{
+		{"server", "", &SpawnCommand{id: "a"}, true},
+		{"own move", "a", &MoveCommand{player: a}, true},
+		{"own attack", "a", &AttackCommand{palyer: a, target: b}, true},
+		{"other move", "a", &MoveCommand{player: b}, false},
+		{"attack as other", "a", &AttackCommand{palyer: b, target: a}, false},
+		{"spawn", "a", &SpawnCommand{id: "a"}, false},
+		{"no players", "a", undoMarker{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := OwnPlayerOnly.Authorize(tt.issuer, tt.command)
+			if tt.allowed != (err == nil) || err != nil && !errors.Is(err, ErrUnauthorized) {
+				t.Fatalf("expected allowed=%v, got %v", tt.allowed, err)
+			}
+		})
+	}
+}
+
+func TestGameServer_Results(t *testing.T) {
+	s := NewGameServer(NewWorld(), WithPolicy(OwnPlayerOnly))
+	spawnPlayers(t, s, map[string][2]int{"a": {0, 0}, "b": {5, 5}})
+	a, b := mustPlayer(t, s.World(), "a"), mustPlayer(t, s.World(), "b")
+
+	tests := []struct {
+		issuer  string
+		command Command
+		status  Status
+		err     error
+	}{
+		{"a", &MoveCommand{player: a, direction: "up"}, StatusAccepted, nil},
+		{"a", &MoveCommand{player: b, direction: "up"}, StatusRejected, ErrUnauthorized},
+		{"b", &AttackCommand{palyer: b, target: b}, StatusRejected, ErrSelfAttack},
+		{"b", &AttackCommand{palyer: b, target: a}, StatusAccepted, nil},
+	}
+	for _, tt := range tests {
+		s.Submit(tt.issuer, tt.command)
+	}
+	results, err := s.ProcessCommands()
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("expected %d results, got %v", len(tests), results)
+	}
+
+	byCommand := make(map[Command]CommandResult)
+	for _, r := range results {
+		byCommand[r.Command] = r
+	}
+	for _, tt := range tests {
+		r := byCommand[tt.command]
+		if r.Issuer != tt.issuer || r.Status != tt.status || !errors.Is(r.Err, tt.err) {
+			t.Fatalf("expected %s %v, got %v", tt.status, tt.err, r)
+		}
+	}
+	if a.y != 1 || a.health != 90 || b.y != 5 {
+		t.Fatalf("unexpected world: %v %v", a, b)
+	}
+}
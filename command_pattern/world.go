@@ -0,0 +1,413 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrOutOfBounds   = errors.New("position out of bounds")
+	ErrBlocked       = errors.New("position is blocked")
+	ErrOccupied      = errors.New("position is occupied")
+	ErrOutOfRange    = errors.New("target out of range")
+	ErrNoLineOfSight = errors.New("no line of sight")
+)
+
+type point struct {
+	x, y int
+}
+
+// World owns the players and the map they stand on. Positions are kept
+// in two indexes: occupied answers "who is on this tile" and cells, a
+// uniform grid of cellSize tiles, answers radius queries without
+// scanning every player.
+type World struct {
+	mu      sync.RWMutex
+	players map[string]*Player
+
+	width, height int
+	blocked       map[point]bool
+	occupied      map[point]*Player
+	cellSize      int
+	cells         map[point]map[*Player]struct{}
+	attackRange   int
+
+	// version counts the changes of the occupied tiles. Each player keeps
+	// the version of its last change, see versionOf.
+	version uint64
+}
+
+type worldOption struct {
+	width, height int
+	blocked       []point
+	cellSize      int
+	attackRange   int
+}
+
+type WorldOption struct {
+	apply func(*worldOption)
+}
+
+// WithBounds limits positions to 0 <= x < width and 0 <= y < height.
+// Without it the world is unbounded.
+func WithBounds(width, height int) *WorldOption {
+	return &WorldOption{
+		apply: func(option *worldOption) {
+			option.width, option.height = width, height
+		},
+	}
+}
+
+// WithTiles loads a map where '#' is a blocking tile and any other
+// character is free. The first row is y = 0. It also sets the bounds.
+func WithTiles(rows ...string) *WorldOption {
+	return &WorldOption{
+		apply: func(option *worldOption) {
+			option.height = len(rows)
+			for y, row := range rows {
+				if len(row) > option.width {
+					option.width = len(row)
+				}
+				for x, tile := range row {
+					if tile == '#' {
+						option.blocked = append(option.blocked, point{x, y})
+					}
+				}
+			}
+		},
+	}
+}
+
+// WithCellSize sets the bucket size of the spatial index.
+func WithCellSize(size int) *WorldOption {
+	return &WorldOption{
+		apply: func(option *worldOption) {
+			option.cellSize = size
+		},
+	}
+}
+
+// WithAttackRange limits attacks to targets within r tiles and in line
+// of sight. 0 means no limit.
+func WithAttackRange(r int) *WorldOption {
+	return &WorldOption{
+		apply: func(option *worldOption) {
+			option.attackRange = r
+		},
+	}
+}
+
+func NewWorld(options ...*WorldOption) *World {
+	opts := &worldOption{cellSize: 16}
+	for _, option := range options {
+		option.apply(opts)
+	}
+	if opts.cellSize <= 0 {
+		opts.cellSize = 16
+	}
+
+	w := &World{
+		players:     make(map[string]*Player),
+		width:       opts.width,
+		height:      opts.height,
+		blocked:     make(map[point]bool),
+		occupied:    make(map[point]*Player),
+		cellSize:    opts.cellSize,
+		cells:       make(map[point]map[*Player]struct{}),
+		attackRange: opts.attackRange,
+	}
+	for _, p := range opts.blocked {
+		w.blocked[p] = true
+	}
+	return w
+}
+
+// AddPlayer places p at its own position, replacing any player with the
+// same id. It does not check the position, see CanEnter.
+func (w *World) AddPlayer(p *Player) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.add(p)
+}
+
+// spawn is AddPlayer with the position checked under the same lock, so
+// two players spawned in parallel cannot end up on the same tile.
+func (w *World) spawn(p *Player) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.canEnter(w.players[p.id], p.x, p.y); err != nil {
+		return err
+	}
+	w.add(p)
+	return nil
+}
+
+func (w *World) add(p *Player) {
+	if old, ok := w.players[p.id]; ok {
+		w.unindex(old)
+		old.world = nil
+	}
+	p.world = w
+	w.players[p.id] = p
+	w.index(p)
+	w.bump(p)
+}
+
+func (w *World) RemovePlayer(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if p, ok := w.players[id]; ok {
+		w.unindex(p)
+		p.world = nil
+		delete(w.players, id)
+	}
+}
+
+func (w *World) Player(id string) (*Player, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	p, ok := w.players[id]
+	return p, ok
+}
+
+// Players returns all players ordered by id.
+func (w *World) Players() []*Player {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	players := make([]*Player, 0, len(w.players))
+	for _, p := range w.players {
+		players = append(players, p)
+	}
+	sortPlayers(players)
+	return players
+}
+
+func sortPlayers(players []*Player) {
+	sort.Slice(players, func(i, j int) bool { return players[i].id < players[j].id })
+}
+
+func (w *World) InBounds(x, y int) bool {
+	if w.width <= 0 || w.height <= 0 {
+		return true
+	}
+	return x >= 0 && x < w.width && y >= 0 && y < w.height
+}
+
+func (w *World) Blocked(x, y int) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.blocked[point{x, y}]
+}
+
+// SetBlocked changes a tile at runtime, e.g. for doors.
+func (w *World) SetBlocked(x, y int, blocked bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if blocked {
+		w.blocked[point{x, y}] = true
+		return
+	}
+	delete(w.blocked, point{x, y})
+}
+
+func (w *World) PlayerAt(x, y int) (*Player, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	p, ok := w.occupied[point{x, y}]
+	return p, ok
+}
+
+// CanEnter reports why p cannot stand on (x, y), or nil if it can.
+// p may be nil for a player that is not in the world yet.
+func (w *World) CanEnter(p *Player, x, y int) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.canEnter(p, x, y)
+}
+
+func (w *World) canEnter(p *Player, x, y int) error {
+	if !w.InBounds(x, y) {
+		return fmt.Errorf("%w: (%d, %d)", ErrOutOfBounds, x, y)
+	}
+	if w.blocked[point{x, y}] {
+		return fmt.Errorf("%w: (%d, %d)", ErrBlocked, x, y)
+	}
+	if other, ok := w.occupied[point{x, y}]; ok && other != p {
+		return fmt.Errorf("%w: (%d, %d) by %q", ErrOccupied, x, y, other.id)
+	}
+	return nil
+}
+
+// move checks and applies a move atomically, so two players validated
+// in parallel cannot end up on the same tile.
+func (w *World) move(p *Player, x, y int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.canEnter(p, x, y); err != nil {
+		return err
+	}
+	w.relocate(p, x, y)
+	return nil
+}
+
+// place puts p on (x, y) without any checks, used to undo moves.
+func (w *World) place(p *Player, x, y int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.relocate(p, x, y)
+}
+
+func (w *World) relocate(p *Player, x, y int) {
+	w.unindex(p)
+	p.x, p.y = x, y
+	w.index(p)
+	w.bump(p)
+}
+
+func (w *World) bump(p *Player) {
+	w.version++
+	p.version = w.version
+}
+
+// versionOf returns the latest version at which one of the players took
+// a tile, so the server can log commands in the order they moved players.
+func (w *World) versionOf(ids []string) uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var version uint64
+	for _, id := range ids {
+		if p, ok := w.players[id]; ok && p.version > version {
+			version = p.version
+		}
+	}
+	return version
+}
+
+func (w *World) cellOf(x, y int) point {
+	return point{floorDiv(x, w.cellSize), floorDiv(y, w.cellSize)}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+func (w *World) index(p *Player) {
+	if _, ok := w.occupied[point{p.x, p.y}]; !ok {
+		w.occupied[point{p.x, p.y}] = p
+	}
+	c := w.cellOf(p.x, p.y)
+	if w.cells[c] == nil {
+		w.cells[c] = make(map[*Player]struct{})
+	}
+	w.cells[c][p] = struct{}{}
+}
+
+func (w *World) unindex(p *Player) {
+	if w.occupied[point{p.x, p.y}] == p {
+		delete(w.occupied, point{p.x, p.y})
+	}
+	c := w.cellOf(p.x, p.y)
+	delete(w.cells[c], p)
+	if len(w.cells[c]) == 0 {
+		delete(w.cells, c)
+	}
+}
+
+// PlayersWithin returns the players whose Euclidean distance to (x, y)
+// is at most r, ordered by id. Only the cells overlapping the circle's
+// bounding box are visited.
+func (w *World) PlayersWithin(x, y, r int) []*Player {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var found []*Player
+	lo, hi := w.cellOf(x-r, y-r), w.cellOf(x+r, y+r)
+	for cx := lo.x; cx <= hi.x; cx++ {
+		for cy := lo.y; cy <= hi.y; cy++ {
+			for p := range w.cells[point{cx, cy}] {
+				if distance2(p.x, p.y, x, y) <= r*r {
+					found = append(found, p)
+				}
+			}
+		}
+	}
+	sortPlayers(found)
+	return found
+}
+
+func distance2(x0, y0, x1, y1 int) int {
+	dx, dy := x1-x0, y1-y0
+	return dx*dx + dy*dy
+}
+
+// LineOfSight walks the tiles between the two points with Bresenham's
+// algorithm and reports whether none of them is blocked. The end points
+// themselves are not checked.
+func (w *World) LineOfSight(x0, y0, x1, y1 int) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	err := dx + dy
+	x, y := x0, y0
+	for {
+		if x == x1 && y == y1 {
+			return true
+		}
+		if (x != x0 || y != y0) && w.blocked[point{x, y}] {
+			return false
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y += sy
+		}
+	}
+}
+
+// CanAttack reports why attacker cannot hit target, or nil if it can.
+func (w *World) CanAttack(attacker, target *Player) error {
+	if w.attackRange <= 0 {
+		return nil
+	}
+	if distance2(attacker.x, attacker.y, target.x, target.y) > w.attackRange*w.attackRange {
+		return fmt.Errorf("%w: %q to %q", ErrOutOfRange, attacker.id, target.id)
+	}
+	if !w.LineOfSight(attacker.x, attacker.y, target.x, target.y) {
+		return fmt.Errorf("%w: %q to %q", ErrNoLineOfSight, attacker.id, target.id)
+	}
+	return nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
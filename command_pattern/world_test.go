@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWorld_LineOfSight(t *testing.T) {
+	world := NewWorld(WithTiles(
+		".....",
+		"..#..",
+		".....",
+	))
+
+	tests := []struct {
+		x0, y0, x1, y1 int
+		want           bool
+	}{
+		{0, 1, 4, 1, false},
+		{0, 0, 4, 0, true},
+		{0, 0, 4, 2, false},
+		{0, 2, 1, 0, true},
+		{2, 0, 2, 2, false},
+		{2, 1, 4, 1, true}, // the end points are not checked
+		{3, 3, 3, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("(%d,%d)-(%d,%d)", tt.x0, tt.y0, tt.x1, tt.y1), func(t *testing.T) {
+			if got := world.LineOfSight(tt.x0, tt.y0, tt.x1, tt.y1); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			if got := world.LineOfSight(tt.x1, tt.y1, tt.x0, tt.y0); got != tt.want {
+				t.Fatalf("reversed: expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWorld_CanAttack(t *testing.T) {
+	world := NewWorld(WithTiles(
+		"......",
+		"..#...",
+		"......",
+	), WithAttackRange(3))
+	archer := &Player{id: "archer", x: 0, y: 1}
+	world.AddPlayer(archer)
+
+	tests := []struct {
+		name string
+		x, y int
+		want error
+	}{
+		{"adjacent", 1, 1, nil},
+		{"diagonal in range", 2, 2, nil},
+		{"behind wall", 3, 1, ErrNoLineOfSight},
+		{"out of range", 4, 0, ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &Player{id: tt.name, x: tt.x, y: tt.y}
+			if err := world.CanAttack(archer, target); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestWorld_PlayersWithin(t *testing.T) {
+	world := NewWorld(WithCellSize(4))
+	for i, pos := range [][2]int{{0, 0}, {3, 0}, {0, -4}, {-3, -3}, {10, 10}, {5, 0}} {
+		world.AddPlayer(&Player{id: fmt.Sprintf("p%d", i), x: pos[0], y: pos[1]})
+	}
+
+	tests := []struct {
+		x, y, r int
+		want    string
+	}{
+		{0, 0, 0, "[p0]"},
+		{0, 0, 4, "[p0 p1 p2]"},
+		{0, 0, 5, "[p0 p1 p2 p3 p5]"},
+		{10, 10, 1, "[p4]"},
+		{20, 20, 3, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("(%d,%d) r=%d", tt.x, tt.y, tt.r), func(t *testing.T) {
+			var ids []string
+			for _, p := range world.PlayersWithin(tt.x, tt.y, tt.r) {
+				ids = append(ids, p.id)
+			}
+			if got := fmt.Sprint(ids); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	// Moving a player updates the index.
+	p0 := mustPlayer(t, world, "p0")
+	if err := p0.Move("up"); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if got := world.PlayersWithin(0, 0, 0); len(got) != 0 {
+		t.Fatalf("expected (0,0) to be empty after moving, got %v", got)
+	}
+}
+
+func TestWorld_MoveRejected(t *testing.T) {
+	world := NewWorld(WithBounds(2, 1))
+	a, b := &Player{id: "a"}, &Player{id: "b", x: 1}
+	world.AddPlayer(a)
+	world.AddPlayer(b)
+
+	tests := []struct {
+		direction string
+		want      error
+	}{
+		{"right", ErrOccupied},
+		{"left", ErrOutOfBounds},
+		{"up", ErrOutOfBounds},
+	}
+	for _, tt := range tests {
+		if err := a.Move(tt.direction); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.direction, tt.want, err)
+		}
+		if a.x != 0 || a.y != 0 {
+			t.Fatalf("%s: expected a to stay put, got %v", tt.direction, a)
+		}
+	}
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
 // Action is the composite component
 type Action interface {
 
-	// Execute does the action
-	Execute()
+	// Execute does the action and reports whether it failed, it should
+	// give up when ctx is done
+	Execute(ctx context.Context) error
+}
+
+// Compensator is implemented by actions that can undo their effect after
+// they succeeded, so a transactional composite can roll them back
+type Compensator interface {
+
+	// Compensate reverts a successful Execute
+	Compensate() error
 }
 
 // PlayerAction is the composite leaf, it implements the Action interface
@@ -18,23 +30,276 @@ type PlayerAction struct {
 	actionTime time.Time
 }
 
-func (p *PlayerAction) Execute() {
+func (p *PlayerAction) Execute(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fmt.Printf("Player %d performed actions at %v\n", p.playerId, p.actionTime)
+	return nil
+}
+
+func (p *PlayerAction) Compensate() error {
+	fmt.Printf("Player %d reverted actions at %v\n", p.playerId, p.actionTime)
+	return nil
+}
+
+// FuncAction is a leaf built from plain functions, undo may be nil
+type FuncAction struct {
+	do   func(ctx context.Context) error
+	undo func() error
+}
+
+func NewFuncAction(do func(ctx context.Context) error, undo func() error) *FuncAction {
+	return &FuncAction{do: do, undo: undo}
+}
+
+func (f *FuncAction) Execute(ctx context.Context) error {
+	return f.do(ctx)
+}
+
+func (f *FuncAction) Compensate() error {
+	if f.undo == nil {
+		return nil
+	}
+	return f.undo()
+}
+
+// FailureMode decides what a CompositeAction does when a child fails
+type FailureMode int
+
+const (
+	// FailFast stops at the first failing child and returns its error
+	FailFast FailureMode = iota
+
+	// AllOrNothing stops at the first failing child and compensates the
+	// children that already succeeded, in reverse order. A failing child
+	// that is itself a CompositeAction is compensated too, so the parts
+	// of it that succeeded are reverted whatever its own mode
+	AllOrNothing
+
+	// BestEffort runs every child and returns all their errors joined
+	BestEffort
+)
+
+var (
+	// ErrNoWinner is returned by a race in which no child succeeded
+	ErrNoWinner = errors.New("no action succeeded")
+
+	// ErrQuorumNotMet is returned when too many children of a quorum failed
+	ErrQuorumNotMet = errors.New("quorum not met")
+
+	// ErrInvalidQuorum is returned by a quorum that needs fewer than one
+	// or more successes than it has children
+	ErrInvalidQuorum = errors.New("invalid quorum")
+)
+
+// RollbackError is returned by an AllOrNothing composite whose rollback
+// did not fully succeed. Cause is the error that triggered the rollback
+type RollbackError struct {
+	Cause         error
+	Compensations []error
+}
+
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("%v (rollback failed: %v)", e.Cause, errors.Join(e.Compensations...))
+}
+
+func (e *RollbackError) Unwrap() []error {
+	return append([]error{e.Cause}, e.Compensations...)
+}
+
+// ChildResult tells whether a child ran, how it ended and how long it took
+type ChildResult struct {
+	Index    int
+	ID       int
+	Action   Action
+	Started  bool
+	Err      error
+	Duration time.Duration
+
+	// Compensated is set when the child succeeded but was rolled back
+	Compensated bool
+
+	// Nested is the result of a child that is itself a CompositeAction
+	Nested *Result
+}
+
+// Result is the report of one CompositeAction run
+type Result struct {
+	Strategy Strategy
+	Children []ChildResult
+	Duration time.Duration
+
+	// Winner is the index of the child that won a race, or -1
+	Winner int
+}
+
+func (r *Result) String() string {
+	var b strings.Builder
+	r.format(&b, "")
+	return b.String()
+}
+
+func (r *Result) format(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%s%s in %v\n", indent, r.Strategy, r.Duration.Round(time.Millisecond))
+	for _, child := range r.Children {
+		status := "ok"
+		switch {
+		case !child.Started:
+			status = "not started"
+		case child.Compensated:
+			status = "compensated"
+		case child.Err != nil:
+			status = child.Err.Error()
+		}
+		fmt.Fprintf(b, "%s  #%d %s in %v\n", indent, child.ID, status, child.Duration.Round(time.Millisecond))
+		if child.Nested != nil {
+			child.Nested.format(b, indent+"    ")
+		}
+	}
 }
 
 // CompositeAction is the composite, it is the collection of Action
 type CompositeAction struct {
-	actions []Action
+	actions  []Action
+	ids      []int
+	nextID   int
+	mode     FailureMode
+	strategy Strategy
+	timeout  time.Duration
+
+	// succeeded holds the indexes of the children of the last run that
+	// still need to be compensated if the parent rolls back, last is that
+	// run's result
+	succeeded []int
+	last      *Result
+}
+
+// NewCompositeAction creates a sequential composite with the given failure mode
+func NewCompositeAction(mode FailureMode, actions ...Action) *CompositeAction {
+	c := &CompositeAction{mode: mode}
+	for _, action := range actions {
+		c.AddAction(action)
+	}
+	return c
 }
 
-func (c *CompositeAction) Execute() {
+// SetStrategy changes how the children are scheduled, see Sequential,
+// Parallel, Race and Quorum
+func (c *CompositeAction) SetStrategy(strategy Strategy) *CompositeAction {
+	c.strategy = strategy
+	return c
+}
+
+// SetTimeout bounds every run of the composite, 0 means no timeout
+func (c *CompositeAction) SetTimeout(timeout time.Duration) *CompositeAction {
+	c.timeout = timeout
+	return c
+}
+
+func (c *CompositeAction) Execute(ctx context.Context) error {
+	_, err := c.Run(ctx)
+	return err
+}
+
+// Run executes the children according to the strategy and the failure
+// mode and reports what happened to each of them. A composite must not
+// be run concurrently with itself
+func (c *CompositeAction) Run(ctx context.Context) (*Result, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	r := &runner{
+		composite: c,
+		result: &Result{
+			Strategy: c.strategy,
+			Children: make([]ChildResult, len(c.actions)),
+			Winner:   -1,
+		},
+	}
+	for i, action := range c.actions {
+		r.result.Children[i] = ChildResult{Index: i, ID: c.ids[i], Action: action}
+	}
+	c.succeeded = c.succeeded[:0]
+	c.last = r.result
+	if err := c.strategy.validate(len(c.actions)); err != nil {
+		return r.result, err
+	}
+
 	// run the composited actions
-	for _, action := range c.actions {
-		action.Execute()
+	var err error
+	if c.strategy.kind == sequential {
+		err = r.sequential(ctx)
+	} else {
+		err = r.concurrent(ctx)
 	}
+	r.result.Duration = time.Since(start)
+	return r.result, err
+}
+
+// rollback compensates the succeeded children in reverse order, trying
+// every one of them even if some compensation fails
+func (c *CompositeAction) rollback(cause error) error {
+	compensations := c.compensate()
+	if len(compensations) == 0 {
+		return cause
+	}
+	return &RollbackError{Cause: cause, Compensations: compensations}
+}
+
+// compensate first reverts the nested composites that failed part way,
+// since only they know which of their own children succeeded, and then
+// the children that succeeded, in reverse order. Failed leaves are left
+// alone, their Compensate only reverts a successful Execute
+func (c *CompositeAction) compensate() []error {
+	var errs []error
+	if c.last != nil {
+		for i := len(c.last.Children) - 1; i >= 0; i-- {
+			child := c.last.Children[i]
+			nested, ok := child.Action.(*CompositeAction)
+			if !ok || !child.Started || child.Err == nil {
+				continue
+			}
+			if err := nested.Compensate(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for i := len(c.succeeded) - 1; i >= 0; i-- {
+		child := &c.last.Children[c.succeeded[i]]
+		if err := compensate(child.Action); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		child.Compensated = true
+	}
+	c.succeeded = c.succeeded[:0]
+	return errs
+}
+
+func compensate(action Action) error {
+	compensator, ok := action.(Compensator)
+	if !ok {
+		return nil
+	}
+	return compensator.Compensate()
+}
+
+// Compensate reverts what the last run did, so a composite can itself be
+// rolled back by its parent, whether that run succeeded or failed
+func (c *CompositeAction) Compensate() error {
+	return errors.Join(c.compensate()...)
 }
 
-// AddAction adds an action to the actions collection
-func (c *CompositeAction) AddAction(action Action) {
+// AddAction adds an action to the actions collection and returns its ID,
+// which stays the same when other children are removed
+func (c *CompositeAction) AddAction(action Action) int {
+	c.nextID++
 	c.actions = append(c.actions, action)
+	c.ids = append(c.ids, c.nextID)
+	return c.nextID
 }
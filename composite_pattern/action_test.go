@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+var errBoom = errors.New("boom")
+
+// journal records what the test actions did and which effects are
+// still applied
+type journal struct {
+	mu      sync.Mutex
+	log     []string
+	applied map[string]bool
+}
+
+func newJournal() *journal {
+	return &journal{applied: map[string]bool{}}
+}
+
+func (j *journal) record(entry, name string, applied bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.log = append(j.log, entry+" "+name)
+	if applied {
+		j.applied[name] = true
+	} else {
+		delete(j.applied, name)
+	}
+}
+
+// step succeeds and can be undone
+func (j *journal) step(name string) Action {
+	return NewFuncAction(
+		func(context.Context) error { j.record("do", name, true); return nil },
+		func() error { j.record("undo", name, false); return nil },
+	)
+}
+
+// fail fails without any effect, its undo must never run
+func (j *journal) fail(name string) Action {
+	return NewFuncAction(
+		func(context.Context) error { return errBoom },
+		func() error { j.record("undo", name, false); return nil },
+	)
+}
+
+func (j *journal) left() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var names []string
+	for name := range j.applied {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCompositeAction_Rollback(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(j *journal) *CompositeAction
+		want  []string
+	}{
+		{
+			name: "all or nothing",
+			build: func(j *journal) *CompositeAction {
+				return NewCompositeAction(AllOrNothing, j.step("a"), j.step("b"), j.fail("c"))
+			},
+			want: []string{"do a", "do b", "undo b", "undo a"},
+		},
+		{
+			name: "fail fast keeps effects",
+			build: func(j *journal) *CompositeAction {
+				return NewCompositeAction(FailFast, j.step("a"), j.fail("b"), j.step("c"))
+			},
+			want: []string{"do a"},
+		},
+		{
+			name: "nested fail fast",
+			build: func(j *journal) *CompositeAction {
+				return NewCompositeAction(AllOrNothing,
+					j.step("a"),
+					NewCompositeAction(FailFast, j.step("b1"), j.fail("b2")),
+				)
+			},
+			want: []string{"do a", "do b1", "undo b1", "undo a"},
+		},
+		{
+			name: "nested best effort",
+			build: func(j *journal) *CompositeAction {
+				return NewCompositeAction(AllOrNothing,
+					j.step("a"),
+					NewCompositeAction(BestEffort, j.step("b1"), j.fail("b2"), j.step("b3")),
+				)
+			},
+			want: []string{"do a", "do b1", "do b3", "undo b3", "undo b1", "undo a"},
+		},
+		{
+			name: "nested all or nothing",
+			build: func(j *journal) *CompositeAction {
+				return NewCompositeAction(AllOrNothing,
+					j.step("a"),
+					NewCompositeAction(AllOrNothing, j.step("b1"), j.fail("b2")),
+				)
+			},
+			want: []string{"do a", "do b1", "undo b1", "undo a"},
+		},
+		{
+			name: "three levels",
+			build: func(j *journal) *CompositeAction {
+				return NewCompositeAction(AllOrNothing,
+					j.step("a"),
+					NewCompositeAction(FailFast,
+						j.step("b"),
+						NewCompositeAction(FailFast, j.step("c1"), j.fail("c2")),
+					),
+				)
+			},
+			want: []string{"do a", "do b", "do c1", "undo c1", "undo b", "undo a"},
+		},
+		{
+			name: "succeeded nested child",
+			build: func(j *journal) *CompositeAction {
+				return NewCompositeAction(AllOrNothing,
+					NewCompositeAction(FailFast, j.step("a1"), j.step("a2")),
+					j.fail("b"),
+				)
+			},
+			want: []string{"do a1", "do a2", "undo a2", "undo a1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := newJournal()
+			if err := tt.build(j).Execute(context.Background()); !errors.Is(err, errBoom) {
+				t.Fatalf("expected errBoom, got %v", err)
+			}
+			if !reflect.DeepEqual(j.log, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, j.log)
+			}
+		})
+	}
+}
+
+func TestCompositeAction_RollbackError(t *testing.T) {
+	undoErr := errors.New("cannot undo")
+	c := NewCompositeAction(AllOrNothing,
+		NewFuncAction(func(context.Context) error { return nil }, func() error { return undoErr }),
+		NewFuncAction(func(context.Context) error { return errBoom }, nil),
+	)
+	err := c.Execute(context.Background())
+	var rollback *RollbackError
+	if !errors.As(err, &rollback) || !errors.Is(err, errBoom) || !errors.Is(err, undoErr) {
+		t.Fatalf("expected a RollbackError wrapping both errors, got %v", err)
+	}
+}
+
+func TestCompositeAction_ConcurrentNestedRollback(t *testing.T) {
+	for _, strategy := range []Strategy{Parallel(0), Parallel(1), Quorum(3)} {
+		t.Run(strategy.String(), func(t *testing.T) {
+			j := newJournal()
+			c := NewCompositeAction(AllOrNothing,
+				j.step("a"),
+				NewCompositeAction(FailFast, j.step("b1"), j.fail("b2")),
+				NewCompositeAction(BestEffort, j.step("c1"), j.step("c2")),
+			).SetStrategy(strategy)
+
+			if err := c.Execute(context.Background()); !errors.Is(err, errBoom) {
+				t.Fatalf("expected errBoom, got %v", err)
+			}
+			if left := j.left(); len(left) != 0 {
+				t.Fatalf("expected every effect to be rolled back, still applied: %v", left)
+			}
+		})
+	}
+}
+
+// valueStep is an action used by value whose type is not comparable
+type valueStep struct {
+	name    string
+	journal *journal
+	tags    []string
+}
+
+func (a valueStep) Execute(context.Context) error {
+	a.journal.record("do", a.name, true)
+	return nil
+}
+
+func (a valueStep) Compensate() error {
+	a.journal.record("undo", a.name, false)
+	return nil
+}
+
+func TestCompositeAction_RollbackUncomparableAction(t *testing.T) {
+	for _, strategy := range []Strategy{Sequential(), Parallel(0)} {
+		j := newJournal()
+		c := NewCompositeAction(AllOrNothing,
+			valueStep{name: "a", journal: j, tags: []string{"x"}},
+			valueStep{name: "b", journal: j},
+			j.fail("c"),
+		).SetStrategy(strategy)
+
+		result, err := c.Run(context.Background())
+		if !errors.Is(err, errBoom) {
+			t.Fatalf("%v: expected errBoom, got %v", strategy, err)
+		}
+		if left := j.left(); len(left) != 0 {
+			t.Fatalf("%v: expected everything compensated, got %v", strategy, left)
+		}
+		if !result.Children[0].Compensated || !result.Children[1].Compensated || result.Children[2].Compensated {
+			t.Fatalf("%v: unexpected compensation flags %+v", strategy, result.Children)
+		}
+	}
+}
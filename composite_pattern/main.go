@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 )
 
@@ -33,5 +36,163 @@ func main() {
 	composite.AddAction(action1)
 	composite.AddAction(action2)
 
-	composite.Execute()
+	if err := composite.Execute(context.Background()); err != nil {
+		fmt.Println(err)
+	}
+
+	transactional()
+	bestEffort()
+	strategies()
+	scripts()
+}
+
+// transactional buys an item: when the delivery fails, the gold and the
+// stock taken before it are given back
+func transactional() {
+	gold, stock := 100, 1
+	trade := NewCompositeAction(AllOrNothing,
+		NewFuncAction(
+			func(context.Context) error { gold -= 30; return nil },
+			func() error { gold += 30; return nil },
+		),
+		NewFuncAction(
+			func(context.Context) error { stock--; return nil },
+			func() error { stock++; return nil },
+		),
+		NewFuncAction(
+			func(context.Context) error { return errors.New("inventory is full") },
+			nil,
+		),
+	)
+
+	err := trade.Execute(context.Background())
+	fmt.Printf("trade: %v, gold %d, stock %d\n", err, gold, stock)
+}
+
+// bestEffort sends rewards to every player and reports all failures
+func bestEffort() {
+	rewards := NewCompositeAction(BestEffort)
+	for _, id := range []int{1, 2, 3} {
+		id := id
+		rewards.AddAction(NewFuncAction(func(context.Context) error {
+			if id == 2 {
+				return fmt.Errorf("player %d is offline", id)
+			}
+			fmt.Printf("reward sent to player %d\n", id)
+			return nil
+		}, nil))
+	}
+	fmt.Println("rewards:", rewards.Execute(context.Background()))
+}
+
+// sleep is a leaf that takes d to finish, or fails when ctx is done first
+func sleep(d time.Duration, err error) Action {
+	return NewFuncAction(func(ctx context.Context) error {
+		select {
+		case <-time.After(d):
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, nil)
+}
+
+func strategies() {
+	// find a match in the fastest region
+	matchmaking := NewCompositeAction(FailFast,
+		sleep(30*time.Millisecond, nil),
+		sleep(10*time.Millisecond, nil),
+		sleep(20*time.Millisecond, errors.New("region is full")),
+	).SetStrategy(Race())
+
+	// a save is durable once two of the three replicas have it
+	save := NewCompositeAction(AllOrNothing,
+		sleep(10*time.Millisecond, nil),
+		sleep(5*time.Millisecond, errors.New("replica down")),
+		sleep(15*time.Millisecond, nil),
+	).SetStrategy(Quorum(2))
+
+	// load assets two at a time, giving up after 30ms
+	load := NewCompositeAction(FailFast,
+		sleep(20*time.Millisecond, nil),
+		sleep(20*time.Millisecond, nil),
+		sleep(20*time.Millisecond, nil),
+		sleep(20*time.Millisecond, nil),
+	).SetStrategy(Parallel(2)).SetTimeout(30 * time.Millisecond)
+
+	login := NewCompositeAction(BestEffort, matchmaking, save, load)
+	result, err := login.Run(context.Background())
+	fmt.Print(result)
+	fmt.Println("login:", err)
+}
+
+// script is an action script as a designer would write it
+const script = `
+# opening of the boss fight
+type: composite
+mode: all_or_nothing
+children:
+  - type: player
+    player_id: 1
+  - type: composite
+    strategy: parallel
+    limit: 2
+    children:
+      - type: player
+        player_id: 2
+      - type: player
+        player_id: 3
+  - type: player
+    player_id: 4
+`
+
+func scripts() {
+	registry := NewActionRegistry()
+	root, err := registry.DecodeYAML([]byte(script))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	describe := func(n Node) string {
+		if p, ok := n.Action.(*PlayerAction); ok {
+			return fmt.Sprintf("player %d", p.playerId)
+		}
+		return "composite"
+	}
+	WalkDepthFirst(root, func(n Node) bool {
+		fmt.Printf("dfs %v %s\n", n.Path, describe(n))
+		return true
+	})
+	WalkBreadthFirst(root, func(n Node) bool {
+		fmt.Printf("bfs %v %s\n", n.Path, describe(n))
+		return n.Depth() < 2
+	})
+
+	third, ok := Find(root, func(n Node) bool {
+		p, ok := n.Action.(*PlayerAction)
+		return ok && p.playerId == 3
+	})
+	if ok {
+		third.Parent.RemoveAction(third.ID)
+	}
+	root.(*CompositeAction).AddAction(&PlayerAction{playerId: 5})
+
+	data, err := registry.EncodeYAML(root)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(string(data))
+
+	again, err := registry.DecodeYAML(data)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	json1, _ := registry.EncodeJSON(root)
+	json2, _ := registry.EncodeJSON(again)
+	fromJSON, _ := registry.DecodeJSON(json1)
+	json3, _ := registry.EncodeJSON(fromJSON)
+	fmt.Println("round trip:", string(json1) == string(json2) && string(json1) == string(json3))
 }
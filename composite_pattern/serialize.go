@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+var (
+	ErrUnknownActionType = errors.New("unknown action type")
+	ErrNotSerializable   = errors.New("action is not serializable")
+	ErrCycle             = errors.New("action tree contains a cycle")
+)
+
+// compositeType is the type name reserved for CompositeAction
+const compositeType = "composite"
+
+// SerializableAction is implemented by leaves that can be written to and
+// read from action scripts. The fields must be JSON-like values: nil,
+// bool, numbers, strings, slices and maps. The keys "type" and "id" are
+// reserved
+type SerializableAction interface {
+	Action
+	MarshalFields() (map[string]interface{}, error)
+	UnmarshalFields(fields map[string]interface{}) error
+}
+
+func (p *PlayerAction) MarshalFields() (map[string]interface{}, error) {
+	fields := map[string]interface{}{"player_id": p.playerId}
+	if !p.actionTime.IsZero() {
+		fields["action_time"] = p.actionTime.Format(time.RFC3339Nano)
+	}
+	return fields, nil
+}
+
+func (p *PlayerAction) UnmarshalFields(fields map[string]interface{}) error {
+	id, err := intField(fields, "player_id")
+	if err != nil {
+		return err
+	}
+	p.playerId = id
+	if raw, ok := fields["action_time"]; ok {
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("field action_time: want a string, got %T", raw)
+		}
+		if p.actionTime, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return fmt.Errorf("field action_time: %w", err)
+		}
+	}
+	return nil
+}
+
+// ActionRegistry maps type names in action scripts to leaf types
+type ActionRegistry struct {
+	factories map[string]func() SerializableAction
+	names     map[reflect.Type]string
+}
+
+// NewActionRegistry returns a registry that knows PlayerAction as "player"
+func NewActionRegistry() *ActionRegistry {
+	r := &ActionRegistry{
+		factories: make(map[string]func() SerializableAction),
+		names:     make(map[reflect.Type]string),
+	}
+	r.Register("player", func() SerializableAction { return &PlayerAction{} })
+	return r
+}
+
+// Register adds a leaf type, factory must return a new empty leaf
+func (r *ActionRegistry) Register(name string, factory func() SerializableAction) {
+	if name == compositeType {
+		panic("composite: type name " + compositeType + " is reserved")
+	}
+	r.factories[name] = factory
+	r.names[reflect.TypeOf(factory())] = name
+}
+
+func (r *ActionRegistry) EncodeJSON(root Action) ([]byte, error) {
+	doc, err := r.encode(root, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (r *ActionRegistry) DecodeJSON(data []byte) (Action, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return r.decode(doc)
+}
+
+func (r *ActionRegistry) EncodeYAML(root Action) ([]byte, error) {
+	doc, err := r.encode(root, nil)
+	if err != nil {
+		return nil, err
+	}
+	return marshalYAML(doc), nil
+}
+
+func (r *ActionRegistry) DecodeYAML(data []byte) (Action, error) {
+	v, err := unmarshalYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("action script must be a mapping, got %T", v)
+	}
+	return r.decode(doc)
+}
+
+var modeNames = map[FailureMode]string{
+	FailFast:     "fail_fast",
+	AllOrNothing: "all_or_nothing",
+	BestEffort:   "best_effort",
+}
+
+// encode turns an action into a document, parents holds the composites
+// being encoded to detect cycles
+func (r *ActionRegistry) encode(action Action, parents []*CompositeAction) (map[string]interface{}, error) {
+	c, ok := action.(*CompositeAction)
+	if !ok {
+		leaf, ok := action.(SerializableAction)
+		name, known := r.names[reflect.TypeOf(action)]
+		if !ok || !known {
+			return nil, fmt.Errorf("%w: %T", ErrNotSerializable, action)
+		}
+		doc, err := leaf.MarshalFields()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if _, ok := doc["type"]; ok {
+			return nil, fmt.Errorf("%s: field type is reserved", name)
+		}
+		if _, ok := doc["id"]; ok {
+			return nil, fmt.Errorf("%s: field id is reserved", name)
+		}
+		doc["type"] = name
+		return doc, nil
+	}
+
+	for _, parent := range parents {
+		if parent == c {
+			return nil, ErrCycle
+		}
+	}
+	parents = append(parents, c)
+
+	doc := map[string]interface{}{
+		"type":     compositeType,
+		"mode":     modeNames[c.mode],
+		"strategy": strategyNames[c.strategy.kind],
+		"next_id":  c.nextID,
+	}
+	if c.strategy.limit > 0 {
+		doc["limit"] = c.strategy.limit
+	}
+	if c.strategy.kind == quorum {
+		doc["need"] = c.strategy.need
+	}
+	if c.timeout > 0 {
+		doc["timeout"] = c.timeout.String()
+	}
+	children := make([]interface{}, len(c.actions))
+	for i, child := range c.actions {
+		childDoc, err := r.encode(child, parents)
+		if err != nil {
+			return nil, fmt.Errorf("child %d: %w", c.ids[i], err)
+		}
+		childDoc["id"] = c.ids[i]
+		children[i] = childDoc
+	}
+	doc["children"] = children
+	return doc, nil
+}
+
+func (r *ActionRegistry) decode(doc map[string]interface{}) (Action, error) {
+	name, _ := doc["type"].(string)
+	if name == compositeType {
+		return r.decodeComposite(doc)
+	}
+
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownActionType, name)
+	}
+	fields := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		if k != "type" && k != "id" {
+			fields[k] = v
+		}
+	}
+	leaf := factory()
+	if err := leaf.UnmarshalFields(fields); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return leaf, nil
+}
+
+func (r *ActionRegistry) decodeComposite(doc map[string]interface{}) (*CompositeAction, error) {
+	c := &CompositeAction{}
+
+	if raw, ok := doc["mode"]; ok {
+		mode, err := lookupName(raw, "mode", modeNames)
+		if err != nil {
+			return nil, err
+		}
+		c.mode = mode
+	}
+	if raw, ok := doc["strategy"]; ok {
+		names := make(map[strategyKind]string, len(strategyNames))
+		for kind, name := range strategyNames {
+			names[strategyKind(kind)] = name
+		}
+		kind, err := lookupName(raw, "strategy", names)
+		if err != nil {
+			return nil, err
+		}
+		c.strategy.kind = kind
+	}
+	for key, dst := range map[string]*int{"limit": &c.strategy.limit, "need": &c.strategy.need, "next_id": &c.nextID} {
+		if _, ok := doc[key]; !ok {
+			continue
+		}
+		v, err := intField(doc, key)
+		if err != nil {
+			return nil, err
+		}
+		*dst = v
+	}
+	if raw, ok := doc["timeout"]; ok {
+		s, _ := raw.(string)
+		timeout, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("field timeout: %w", err)
+		}
+		c.timeout = timeout
+	}
+
+	children, ok := doc["children"].([]interface{})
+	if !ok && doc["children"] != nil {
+		return nil, fmt.Errorf("field children: want a list, got %T", doc["children"])
+	}
+	seen := make(map[int]bool, len(children))
+	for i, raw := range children {
+		childDoc, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("child %d: want a mapping, got %T", i, raw)
+		}
+
+		// children written by hand may leave out their ids
+		id := c.nextID + 1
+		if _, ok := childDoc["id"]; ok {
+			var err error
+			if id, err = intField(childDoc, "id"); err != nil {
+				return nil, fmt.Errorf("child %d: %w", i, err)
+			}
+		}
+		if id <= 0 || seen[id] {
+			return nil, fmt.Errorf("child %d: invalid or duplicate id %d", i, id)
+		}
+		seen[id] = true
+
+		child, err := r.decode(childDoc)
+		if err != nil {
+			return nil, fmt.Errorf("child %d: %w", id, err)
+		}
+		c.actions = append(c.actions, child)
+		c.ids = append(c.ids, id)
+		if id > c.nextID {
+			c.nextID = id
+		}
+	}
+	return c, nil
+}
+
+func lookupName[T comparable](raw interface{}, field string, names map[T]string) (T, error) {
+	s, _ := raw.(string)
+	for value, name := range names {
+		if name == s {
+			return value, nil
+		}
+	}
+	var zero T
+	return zero, fmt.Errorf("field %s: unknown value %v", field, raw)
+}
+
+// intField reads an integer that JSON decoded as float64 or YAML as int64
+func intField(fields map[string]interface{}, key string) (int, error) {
+	switch v := fields[key].(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("field %s: want an integer, got %v", key, fields[key])
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// greet is a custom leaf with a string field
+type greet struct {
+	text string
+}
+
+func (g *greet) Execute(context.Context) error { return nil }
+
+func (g *greet) MarshalFields() (map[string]interface{}, error) {
+	return map[string]interface{}{"text": g.text}, nil
+}
+
+func (g *greet) UnmarshalFields(fields map[string]interface{}) error {
+	g.text, _ = fields["text"].(string)
+	return nil
+}
+
+func TestActionRegistry_RoundTrip(t *testing.T) {
+	registry := NewActionRegistry()
+	registry.Register("greet", func() SerializableAction { return &greet{} })
+
+	root := playerTree()
+	root.SetTimeout(time.Second)
+	root.AddAction(&greet{text: "hello: \"world\" # not a comment"})
+	root.AddAction(NewCompositeAction(BestEffort).SetStrategy(Quorum(1).WithLimit(1)))
+	root.AddAction(&PlayerAction{playerId: 6, actionTime: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)})
+	root.RemoveAction(1)
+
+	formats := []struct {
+		name   string
+		encode func(Action) ([]byte, error)
+		decode func([]byte) (Action, error)
+	}{
+		{"json", registry.EncodeJSON, registry.DecodeJSON},
+		{"yaml", registry.EncodeYAML, registry.DecodeYAML},
+	}
+	want, err := registry.EncodeJSON(root)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			data, err := f.encode(root)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			decoded, err := f.decode(data)
+			if err != nil {
+				t.Fatalf("decode: %v\n%s", err, data)
+			}
+			if got, _ := registry.EncodeJSON(decoded); string(got) != string(want) {
+				t.Fatalf("round trip changed the tree:\n%s\nwant\n%s", got, want)
+			}
+			c := decoded.(*CompositeAction)
+			if !reflect.DeepEqual(c.IDs(), root.IDs()) || c.nextID != root.nextID || c.timeout != time.Second {
+				t.Fatalf("expected ids %v next %d, got %v next %d", root.IDs(), root.nextID, c.IDs(), c.nextID)
+			}
+		})
+	}
+}
+
+func TestActionRegistry_DecodeYAMLScript(t *testing.T) {
+	script := `
+# written by hand, without ids
+type: composite
+mode: all_or_nothing
+strategy: quorum
+need: 2
+children:
+  - type: player
+    player_id: 1   # the tank
+  - type: player
+    player_id: 2
+  - type: composite
+    children: []
+`
+	root, err := NewActionRegistry().DecodeYAML([]byte(script))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	c := root.(*CompositeAction)
+	if c.mode != AllOrNothing || c.strategy != Quorum(2) || !reflect.DeepEqual(c.IDs(), []int{1, 2, 3}) {
+		t.Fatalf("unexpected composite: mode %v strategy %v ids %v", c.mode, c.strategy, c.IDs())
+	}
+	if p := c.actions[0].(*PlayerAction); p.playerId != 1 {
+		t.Fatalf("expected player 1, got %d", p.playerId)
+	}
+}
+
+func TestActionRegistry_Errors(t *testing.T) {
+	registry := NewActionRegistry()
+	cyclic := NewCompositeAction(FailFast)
+	cyclic.AddAction(cyclic)
+
+	encodeTests := []struct {
+		name string
+		root Action
+		want error
+	}{
+		{"not serializable", NewCompositeAction(FailFast, NewFuncAction(nil, nil)), ErrNotSerializable},
+		{"unregistered", &greet{}, ErrNotSerializable},
+		{"cycle", cyclic, ErrCycle},
+	}
+	for _, tt := range encodeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := registry.EncodeJSON(tt.root); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	decodeTests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"unknown type", "type: dragon", ErrUnknownActionType.Error()},
+		{"unknown mode", "type: composite\nmode: maybe", "field mode"},
+		{"duplicate id", "type: composite\nchildren:\n  - type: player\n    id: 1\n    player_id: 1\n  - type: player\n    id: 1\n    player_id: 2", "duplicate id"},
+		{"bad field", "type: player\nplayer_id: one", "field player_id"},
+		{"not a mapping", "- type: player", "must be a mapping"},
+	}
+	for _, tt := range decodeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := registry.DecodeYAML([]byte(tt.script)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type strategyKind int
+
+const (
+	sequential strategyKind = iota
+	parallel
+	race
+	quorum
+)
+
+var strategyNames = [...]string{"sequential", "parallel", "race", "quorum"}
+
+// Strategy decides how a CompositeAction schedules its children
+type Strategy struct {
+	kind  strategyKind
+	limit int
+	need  int
+}
+
+// Sequential runs the children one after another, it is the default
+func Sequential() Strategy {
+	return Strategy{kind: sequential}
+}
+
+// Parallel runs at most limit children at a time, limit <= 0 means all
+// of them at once
+func Parallel(limit int) Strategy {
+	return Strategy{kind: parallel, limit: limit}
+}
+
+// Race runs the children concurrently and stops the others as soon as
+// one succeeds. Losers that still succeed are compensated
+func Race() Strategy {
+	return Strategy{kind: race}
+}
+
+// Quorum runs the children concurrently and succeeds once need of them
+// succeeded, or fails as soon as that is no longer possible. need must
+// be between 1 and the number of children, otherwise the run fails with
+// ErrInvalidQuorum
+func Quorum(need int) Strategy {
+	return Strategy{kind: quorum, need: need}
+}
+
+// WithLimit bounds the concurrency of a Race or Quorum
+func (s Strategy) WithLimit(limit int) Strategy {
+	s.limit = limit
+	return s
+}
+
+func (s Strategy) String() string {
+	name := strategyNames[s.kind]
+	if s.kind == quorum {
+		name = fmt.Sprintf("quorum(%d)", s.need)
+	}
+	if s.kind != sequential && s.limit > 0 {
+		name = fmt.Sprintf("%s limit %d", name, s.limit)
+	}
+	return name
+}
+
+// validate checks the strategy against the number of children
+func (s Strategy) validate(children int) error {
+	if s.kind == quorum && (s.need < 1 || s.need > children) {
+		return fmt.Errorf("%w: need %d of %d", ErrInvalidQuorum, s.need, children)
+	}
+	return nil
+}
+
+// runner executes one run of a CompositeAction and fills its Result
+type runner struct {
+	composite *CompositeAction
+	result    *Result
+}
+
+func (r *runner) run(ctx context.Context, i int) error {
+	child := &r.result.Children[i]
+	child.Started = true
+	start := time.Now()
+
+	var err error
+	if nested, ok := child.Action.(*CompositeAction); ok {
+		child.Nested, err = nested.Run(ctx)
+	} else {
+		err = child.Action.Execute(ctx)
+	}
+	child.Duration = time.Since(start)
+	child.Err = err
+	return err
+}
+
+func (r *runner) sequential(ctx context.Context) error {
+	c := r.composite
+	var errs []error
+	for i := range c.actions {
+		if err := ctx.Err(); err != nil {
+			return r.fail(err, errs)
+		}
+		if err := r.run(ctx, i); err != nil {
+			err = fmt.Errorf("action %d: %w", i, err)
+			if c.mode == BestEffort {
+				errs = append(errs, err)
+				continue
+			}
+			return r.fail(err, nil)
+		}
+		c.succeeded = append(c.succeeded, i)
+	}
+	return errors.Join(errs...)
+}
+
+// fail ends a run early because of err
+func (r *runner) fail(err error, errs []error) error {
+	switch r.composite.mode {
+	case AllOrNothing:
+		return r.composite.rollback(err)
+	case BestEffort:
+		return errors.Join(append(errs, err)...)
+	}
+	return err
+}
+
+func (r *runner) concurrent(parent context.Context) error {
+	c := r.composite
+	n := len(c.actions)
+	limit := c.strategy.limit
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	need := c.strategy.need
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	type outcome struct {
+		index int
+		err   error
+	}
+	finished := make(chan outcome)
+	var errs []error
+	var successes []int
+	running, next, stopped := 0, 0, false
+	for {
+		for !stopped && running < limit && next < n && ctx.Err() == nil {
+			i := next
+			next++
+			running++
+			go func() { finished <- outcome{i, r.run(ctx, i)} }()
+		}
+		if running == 0 {
+			break
+		}
+
+		o := <-finished
+		running--
+		if o.err != nil {
+			errs = append(errs, fmt.Errorf("action %d: %w", o.index, o.err))
+		} else {
+			successes = append(successes, o.index)
+		}
+		if !stopped && r.settled(o.err, len(successes), len(errs), n, need) {
+			stopped = true
+			cancel()
+		}
+	}
+	if next < n && parent.Err() != nil {
+		errs = append(errs, parent.Err())
+	}
+
+	switch c.strategy.kind {
+	case race:
+		return r.finishRace(successes, errs)
+	case quorum:
+		return r.finishQuorum(successes, errs, need)
+	}
+	r.keep(successes)
+	if len(errs) == 0 {
+		return nil
+	}
+	if c.mode == BestEffort {
+		return errors.Join(errs...)
+	}
+	return r.fail(errs[0], nil)
+}
+
+// settled reports whether the outcome of a concurrent run is decided,
+// so the children still running can be cancelled
+func (r *runner) settled(err error, successes, failures, n, need int) bool {
+	switch r.composite.strategy.kind {
+	case race:
+		return err == nil
+	case quorum:
+		return successes >= need || failures > n-need
+	}
+	return err != nil && r.composite.mode != BestEffort
+}
+
+// keep records the successful children in start order, so a rollback
+// compensates them in reverse start order
+func (r *runner) keep(indexes []int) {
+	done := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		done[i] = true
+	}
+	for i := range r.composite.actions {
+		if done[i] {
+			r.composite.succeeded = append(r.composite.succeeded, i)
+		}
+	}
+}
+
+func (r *runner) finishRace(successes []int, errs []error) error {
+	if len(successes) == 0 {
+		return fmt.Errorf("%w: %w", ErrNoWinner, errors.Join(errs...))
+	}
+	winner := successes[0]
+	r.result.Winner = winner
+	r.keep([]int{winner})
+	for _, i := range successes[1:] {
+		child := &r.result.Children[i]
+		if err := compensate(child.Action); err != nil {
+			child.Err = fmt.Errorf("compensate loser: %w", err)
+			continue
+		}
+		child.Compensated = true
+	}
+	return nil
+}
+
+func (r *runner) finishQuorum(successes []int, errs []error, need int) error {
+	r.keep(successes)
+	if len(successes) >= need {
+		return nil
+	}
+	err := fmt.Errorf("%w: %d of %d succeeded: %w", ErrQuorumNotMet, len(successes), need, errors.Join(errs...))
+	if r.composite.mode == AllOrNothing {
+		return r.composite.rollback(err)
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func succeed() Action {
+	return NewFuncAction(func(context.Context) error { return nil }, nil)
+}
+
+func failing() Action {
+	return NewFuncAction(func(context.Context) error { return errBoom }, nil)
+}
+
+// blocking waits until its ctx is done
+func blocking() Action {
+	return NewFuncAction(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil)
+}
+
+func TestCompositeAction_Strategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		mode     FailureMode
+		children []Action
+		want     error
+		started  int
+		winner   int
+	}{
+		{"sequential", Sequential(), FailFast, []Action{succeed(), succeed()}, nil, 2, -1},
+		{"sequential fail fast", Sequential(), FailFast, []Action{failing(), succeed()}, errBoom, 1, -1},
+		{"sequential best effort", Sequential(), BestEffort, []Action{failing(), succeed()}, errBoom, 2, -1},
+		{"parallel", Parallel(0), FailFast, []Action{succeed(), succeed(), succeed()}, nil, 3, -1},
+		{"parallel cancels on failure", Parallel(0), FailFast, []Action{blocking(), failing()}, errBoom, 2, -1},
+		{"race", Race(), FailFast, []Action{blocking(), succeed()}, nil, 2, 1},
+		{"race without winner", Race(), FailFast, []Action{failing(), failing()}, ErrNoWinner, 2, -1},
+		{"quorum met", Quorum(2), FailFast, []Action{succeed(), failing(), succeed()}, nil, 3, -1},
+		{"quorum stops once met", Quorum(1).WithLimit(1), FailFast, []Action{succeed(), blocking()}, nil, 1, -1},
+		{"quorum not met", Quorum(2), FailFast, []Action{failing(), failing(), succeed()}, ErrQuorumNotMet, 3, -1},
+		{"quorum of zero", Quorum(0), FailFast, []Action{succeed()}, ErrInvalidQuorum, 0, -1},
+		{"quorum negative", Quorum(-1), FailFast, []Action{succeed()}, ErrInvalidQuorum, 0, -1},
+		{"quorum over children", Quorum(3), FailFast, []Action{succeed(), succeed()}, ErrInvalidQuorum, 0, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCompositeAction(tt.mode, tt.children...).SetStrategy(tt.strategy)
+			result, err := c.Run(context.Background())
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			started := 0
+			for _, child := range result.Children {
+				if child.Started {
+					started++
+				}
+			}
+			if started != tt.started || result.Winner != tt.winner {
+				t.Fatalf("expected %d started and winner %d, got %d and %d\n%v", tt.started, tt.winner, started, result.Winner, result)
+			}
+		})
+	}
+}
+
+func TestCompositeAction_ParallelLimit(t *testing.T) {
+	var running, peak int32
+	child := func() Action {
+		return NewFuncAction(func(context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}, nil)
+	}
+	c := NewCompositeAction(FailFast, child(), child(), child(), child(), child()).SetStrategy(Parallel(2))
+	if err := c.Execute(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if peak > 2 {
+		t.Fatalf("expected at most 2 children at a time, got %d", peak)
+	}
+}
+
+func TestCompositeAction_RaceCompensatesLosers(t *testing.T) {
+	j := newJournal()
+	release := make(chan struct{})
+	late := NewFuncAction(
+		func(context.Context) error { <-release; j.record("do", "late", true); return nil },
+		func() error { j.record("undo", "late", false); return nil },
+	)
+	first := NewFuncAction(
+		func(context.Context) error { j.record("do", "first", true); close(release); return nil },
+		func() error { j.record("undo", "first", false); return nil },
+	)
+
+	result, err := NewCompositeAction(FailFast, late, first).SetStrategy(Race()).Run(context.Background())
+	if err != nil || result.Winner != 1 {
+		t.Fatalf("expected the second child to win, got %d, %v", result.Winner, err)
+	}
+	if !result.Children[0].Compensated {
+		t.Fatalf("expected the late loser to be compensated\n%v", result)
+	}
+	if left := j.left(); len(left) != 1 || left[0] != "first" {
+		t.Fatalf("expected only the winner to stay applied, got %v", left)
+	}
+}
+
+func TestCompositeAction_Timeout(t *testing.T) {
+	c := NewCompositeAction(FailFast, blocking(), blocking()).
+		SetStrategy(Parallel(0)).
+		SetTimeout(10 * time.Millisecond)
+	if err := c.Execute(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+// RemoveAction removes the child with the given ID and returns it
+func (c *CompositeAction) RemoveAction(id int) (Action, bool) {
+	for i, childID := range c.ids {
+		if childID != id {
+			continue
+		}
+		action := c.actions[i]
+		c.actions = append(c.actions[:i], c.actions[i+1:]...)
+		c.ids = append(c.ids[:i], c.ids[i+1:]...)
+		return action, true
+	}
+	return nil, false
+}
+
+// Child returns the child with the given ID
+func (c *CompositeAction) Child(id int) (Action, bool) {
+	for i, childID := range c.ids {
+		if childID == id {
+			return c.actions[i], true
+		}
+	}
+	return nil, false
+}
+
+// IDs returns the IDs of the children in execution order
+func (c *CompositeAction) IDs() []int {
+	return append([]int(nil), c.ids...)
+}
+
+// Node is an action visited by a walk
+type Node struct {
+	Action Action
+
+	// Parent is nil for the root
+	Parent *CompositeAction
+
+	// ID is the child ID within Parent, 0 for the root
+	ID int
+
+	// Path holds the child IDs from the root down to this node
+	Path []int
+}
+
+// Depth is 0 for the root
+func (n Node) Depth() int {
+	return len(n.Path)
+}
+
+// children lists the nodes directly below n
+func (n Node) children() []Node {
+	c, ok := n.Action.(*CompositeAction)
+	if !ok {
+		return nil
+	}
+	nodes := make([]Node, len(c.actions))
+	for i, action := range c.actions {
+		path := make([]int, len(n.Path)+1)
+		copy(path, n.Path)
+		path[len(n.Path)] = c.ids[i]
+		nodes[i] = Node{Action: action, Parent: c, ID: c.ids[i], Path: path}
+	}
+	return nodes
+}
+
+// WalkDepthFirst visits root and its descendants in pre-order until fn
+// returns false, and reports whether the walk was completed. A composite
+// reachable twice, e.g. through a cycle, is only expanded once
+func WalkDepthFirst(root Action, fn func(Node) bool) bool {
+	visited := make(map[*CompositeAction]bool)
+	var walk func(n Node) bool
+	walk = func(n Node) bool {
+		if !fn(n) {
+			return false
+		}
+		if !expand(n, visited) {
+			return true
+		}
+		for _, child := range n.children() {
+			if !walk(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return walk(Node{Action: root})
+}
+
+// WalkBreadthFirst visits root and its descendants level by level until
+// fn returns false, and reports whether the walk was completed
+func WalkBreadthFirst(root Action, fn func(Node) bool) bool {
+	visited := make(map[*CompositeAction]bool)
+	queue := []Node{{Action: root}}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if !fn(n) {
+			return false
+		}
+		if expand(n, visited) {
+			queue = append(queue, n.children()...)
+		}
+	}
+	return true
+}
+
+func expand(n Node, visited map[*CompositeAction]bool) bool {
+	c, ok := n.Action.(*CompositeAction)
+	if !ok || visited[c] {
+		return false
+	}
+	visited[c] = true
+	return true
+}
+
+// Find returns the first node in depth-first order that matches
+func Find(root Action, match func(Node) bool) (Node, bool) {
+	var found Node
+	ok := !WalkDepthFirst(root, func(n Node) bool {
+		if match(n) {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found, ok
+}
+
+// FindAll returns every node in depth-first order that matches
+func FindAll(root Action, match func(Node) bool) []Node {
+	var found []Node
+	WalkDepthFirst(root, func(n Node) bool {
+		if match(n) {
+			found = append(found, n)
+		}
+		return true
+	})
+	return found
+}
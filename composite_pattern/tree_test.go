@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// playerTree builds
+//
+//	root
+//	├── #1 player 1
+//	├── #2 composite
+//	│   ├── #1 player 2
+//	│   └── #2 player 3
+//	└── #3 player 4
+func playerTree() *CompositeAction {
+	return NewCompositeAction(AllOrNothing,
+		&PlayerAction{playerId: 1},
+		NewCompositeAction(FailFast, &PlayerAction{playerId: 2}, &PlayerAction{playerId: 3}).SetStrategy(Parallel(2)),
+		&PlayerAction{playerId: 4},
+	)
+}
+
+func label(n Node) string {
+	if p, ok := n.Action.(*PlayerAction); ok {
+		return fmt.Sprintf("p%d", p.playerId)
+	}
+	return fmt.Sprintf("c%v", n.Path)
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		name     string
+		walk     func(Action, func(Node) bool) bool
+		stop     string
+		want     []string
+		complete bool
+	}{
+		{"depth first", WalkDepthFirst, "", []string{"c[]", "p1", "c[2]", "p2", "p3", "p4"}, true},
+		{"breadth first", WalkBreadthFirst, "", []string{"c[]", "p1", "c[2]", "p4", "p2", "p3"}, true},
+		{"depth first stops", WalkDepthFirst, "p2", []string{"c[]", "p1", "c[2]", "p2"}, false},
+		{"breadth first stops", WalkBreadthFirst, "p4", []string{"c[]", "p1", "c[2]", "p4"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			complete := tt.walk(playerTree(), func(n Node) bool {
+				got = append(got, label(n))
+				return label(n) != tt.stop
+			})
+			if !reflect.DeepEqual(got, tt.want) || complete != tt.complete {
+				t.Fatalf("expected %v (complete %v), got %v (complete %v)", tt.want, tt.complete, got, complete)
+			}
+		})
+	}
+}
+
+func TestWalk_Cycle(t *testing.T) {
+	root := playerTree()
+	root.AddAction(root)
+	n := 0
+	WalkDepthFirst(root, func(Node) bool { n++; return true })
+	if n != 7 {
+		t.Fatalf("expected the cycle to be expanded once, visited %d nodes", n)
+	}
+}
+
+func TestFindAndRemove(t *testing.T) {
+	root := playerTree()
+	isPlayer := func(id int) func(Node) bool {
+		return func(n Node) bool {
+			p, ok := n.Action.(*PlayerAction)
+			return ok && p.playerId == id
+		}
+	}
+
+	third, ok := Find(root, isPlayer(3))
+	if !ok || !reflect.DeepEqual(third.Path, []int{2, 2}) || third.Depth() != 2 {
+		t.Fatalf("unexpected node for player 3: %+v", third)
+	}
+	if _, ok := Find(root, isPlayer(9)); ok {
+		t.Fatalf("expected player 9 not to be found")
+	}
+	leaves := FindAll(root, func(n Node) bool { _, ok := n.Action.(*PlayerAction); return ok })
+	if len(leaves) != 4 {
+		t.Fatalf("expected 4 leaves, got %d", len(leaves))
+	}
+
+	// IDs stay stable when siblings are removed
+	if removed, ok := third.Parent.RemoveAction(third.ID); !ok || removed != third.Action {
+		t.Fatalf("expected player 3 to be removed")
+	}
+	if _, ok := root.RemoveAction(1); !ok {
+		t.Fatalf("expected child 1 to be removed")
+	}
+	if _, ok := root.RemoveAction(1); ok {
+		t.Fatalf("expected a removed id not to be found again")
+	}
+	if id := root.AddAction(&PlayerAction{playerId: 5}); id != 4 {
+		t.Fatalf("expected new child to get id 4, got %d", id)
+	}
+	if got := root.IDs(); !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Fatalf("unexpected ids %v", got)
+	}
+	if child, ok := root.Child(3); !ok || child.(*PlayerAction).playerId != 4 {
+		t.Fatalf("expected child 3 to still be player 4, got %v", child)
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The standard library has no YAML support, so this file implements the
+// small subset needed for action scripts: block mappings, block
+// sequences, comments and scalars (null, bools, numbers, plain, single
+// and double quoted strings, the latter with Go escapes). Anchors,
+// aliases, tags, directives, block and multi-line scalars and flow
+// collections other than [] and {} are not supported, and decoding them
+// fails rather than reading them as plain strings.
+
+func marshalYAML(v interface{}) []byte {
+	var b strings.Builder
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			b.WriteString("{}\n")
+			break
+		}
+		writeYAMLMap(&b, v, 0)
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("[]\n")
+			break
+		}
+		writeYAMLList(&b, v, 0)
+	default:
+		b.WriteString(yamlScalar(v))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+// yamlKeys puts type and id first and children last, so documents read
+// top-down like the tree they describe
+func yamlKeys(m map[string]interface{}) []string {
+	rank := func(k string) int {
+		switch k {
+		case "type":
+			return 0
+		case "id":
+			return 1
+		case "children":
+			return 3
+		}
+		return 2
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if rank(keys[i]) != rank(keys[j]) {
+			return rank(keys[i]) < rank(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func writeYAMLMap(b *strings.Builder, m map[string]interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, k := range yamlKeys(m) {
+		b.WriteString(pad)
+		b.WriteString(yamlString(k))
+		b.WriteByte(':')
+		writeYAMLValue(b, m[k], indent)
+	}
+}
+
+func writeYAMLList(b *strings.Builder, list []interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok && len(m) > 0 {
+			// write the mapping two columns in and turn the first
+			// indentation into the "- " marker
+			var sub strings.Builder
+			writeYAMLMap(&sub, m, indent+2)
+			b.WriteString(pad)
+			b.WriteString("- ")
+			b.WriteString(sub.String()[indent+2:])
+			continue
+		}
+		b.WriteString(pad)
+		b.WriteByte('-')
+		writeYAMLValue(b, item, indent)
+	}
+}
+
+// writeYAMLValue writes what follows "key:" or "-"
+func writeYAMLValue(b *strings.Builder, v interface{}, indent int) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			b.WriteByte('\n')
+			writeYAMLMap(b, v, indent+2)
+			return
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			b.WriteByte('\n')
+			writeYAMLList(b, v, indent+2)
+			return
+		}
+	}
+	b.WriteByte(' ')
+	b.WriteString(yamlScalar(v))
+	b.WriteByte('\n')
+}
+
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return yamlString(v)
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	}
+	return yamlString(fmt.Sprint(v))
+}
+
+var plainYAML = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_./-]*$`)
+
+// yamlString quotes s unless it can be written plain without being read
+// back as something else
+func yamlString(s string) string {
+	if plainYAML.MatchString(s) {
+		if v, err := parseYAMLScalar(s); err == nil {
+			if _, ok := v.(string); ok {
+				return s
+			}
+		}
+	}
+	return strconv.Quote(s)
+}
+
+type yamlLine struct {
+	no     int
+	indent int
+	text   string
+}
+
+func unmarshalYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{no: i + 1, indent: len(raw) - len(text), text: text})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	v, err := p.node(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	line := p.lines[len(p.lines)-1].no
+	if p.pos < len(p.lines) {
+		line = p.lines[p.pos].no
+	}
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// node parses the mapping, sequence or scalar starting at the current
+// line, which must be indented by exactly indent
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	if isYAMLItem(line.text) {
+		return p.sequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.mapping(indent)
+	}
+	v, err := parseYAMLScalar(line.text)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.pos++
+	return v, nil
+}
+
+func (p *yamlParser) sequence(indent int) ([]interface{}, error) {
+	list := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if stripYAMLComment(rest) == "" {
+			p.pos++
+			item, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			continue
+		}
+
+		// "- key: value" starts a mapping whose keys line up with key
+		column := indent + len(line.text) - len(rest)
+		p.lines[p.pos] = yamlLine{no: line.no, indent: column, text: rest}
+		item, err := p.node(column)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		key, rest, ok := splitYAMLKey(p.lines[p.pos].text)
+		if !ok {
+			return nil, p.errorf("expected key: value")
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		if rest != "" {
+			v, err := parseYAMLScalar(rest)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			m[key] = v
+			p.pos++
+			continue
+		}
+		p.pos++
+		// a sequence may sit at the same indentation as its key
+		if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLItem(p.lines[p.pos].text) {
+			list, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = list
+			continue
+		}
+		v, err := p.nested(indent)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// nested parses the block below a line indented by indent, or returns
+// nil if there is none
+func (p *yamlParser) nested(indent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+		return nil, nil
+	}
+	return p.node(p.lines[p.pos].indent)
+}
+
+// splitYAMLKey splits "key: value" and "key:", the key may be quoted
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, `'`) {
+		end := closingQuote(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		v, err := parseYAMLScalar(text[:end+1])
+		key, ok := v.(string)
+		return key, stripYAMLComment(text[end+2:]), err == nil && ok
+	}
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false
+		}
+		i = len(text) - 1
+	}
+	return text[:i], stripYAMLComment(text[i+1:]), true
+}
+
+// stripYAMLComment trims rest and drops it if it is only a comment, so a
+// block can follow "key: # comment" or "- # comment"
+func stripYAMLComment(rest string) string {
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "#") {
+		return ""
+	}
+	return rest
+}
+
+// closingQuote returns the index of the quote that ends the string at
+// the start of text, or -1
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote:
+			if quote == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlNumber leaves out what strconv accepts but YAML reads as strings,
+// such as inf, nan, hex and underscores
+var yamlNumber = regexp.MustCompile(`^[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+
+var errYAMLUnsupported = errors.New("unsupported syntax")
+
+func parseYAMLScalar(text string) (interface{}, error) {
+	if text == "" {
+		return nil, nil
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return nil, errors.New("unterminated quoted string")
+		}
+		if rest := strings.TrimSpace(text[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("unexpected %q after quoted string", rest)
+		}
+		if text[0] == '\'' {
+			return strings.ReplaceAll(text[1:end], "''", "'"), nil
+		}
+		s, err := strconv.Unquote(text[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape in %s", text[:end+1])
+		}
+		return s, nil
+	}
+
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = strings.TrimSpace(text[:i])
+	}
+	switch text {
+	case "null", "~":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "[]":
+		return []interface{}{}, nil
+	case "{}":
+		return map[string]interface{}{}, nil
+	}
+	// indicators that start flow collections, block scalars, anchors,
+	// aliases, tags and directives
+	if strings.ContainsRune("[]{}|>&*!%@`", rune(text[0])) {
+		return nil, fmt.Errorf("%w %q", errYAMLUnsupported, text)
+	}
+	if strings.Contains(text, ": ") || strings.HasSuffix(text, ":") {
+		return nil, fmt.Errorf("%w %q, quote scalars that contain \": \"", errYAMLUnsupported, text)
+	}
+	if !yamlNumber.MatchString(text) {
+		return text, nil
+	}
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f, nil
+	}
+	return text, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want interface{}
+	}{
+		{"empty", "# only a comment\n\n", nil},
+		{"document marker", "---\na: 1\n", map[string]interface{}{"a": int64(1)}},
+		{
+			"scalars",
+			"null: null\ntilde: ~\nempty:\nyes: true\nno: false\nint: -2\nfloat: 1.5\nexp: 1e3\n" +
+				"inf: inf\nhex: 0x10\nunder: 1_000\nplain: hello world\npath: a/b.c-d\n",
+			map[string]interface{}{
+				"null": nil, "tilde": nil, "empty": nil, "yes": true, "no": false,
+				"int": int64(-2), "float": 1.5, "exp": 1000.0,
+				"inf": "inf", "hex": "0x10", "under": "1_000", "plain": "hello world", "path": "a/b.c-d",
+			},
+		},
+		{
+			"quoted",
+			`double: "a: b # c\n\"d\""` + "\n" +
+				`single: 'it''s # here'` + "\n" +
+				`"quoted key": 'x'   # comment` + "\n" +
+				`number: "1"` + "\n",
+			map[string]interface{}{
+				"double": "a: b # c\n\"d\"", "single": "it's # here", "quoted key": "x", "number": "1",
+			},
+		},
+		{
+			"comments",
+			"# header\na: 1 # trailing\n\n  # indented comment\nb:   # before a block\n  c: 2\n",
+			map[string]interface{}{"a": int64(1), "b": map[string]interface{}{"c": int64(2)}},
+		},
+		{
+			"nested mappings",
+			"a:\n    b:\n        c: x\n    d: y\ne: z\n",
+			map[string]interface{}{
+				"a": map[string]interface{}{"b": map[string]interface{}{"c": "x"}, "d": "y"},
+				"e": "z",
+			},
+		},
+		{
+			"sequence at key indentation",
+			"list:\n- 1\n- two\nnext: 3\n",
+			map[string]interface{}{"list": []interface{}{int64(1), "two"}, "next": int64(3)},
+		},
+		{
+			"sequence of mappings",
+			"- a: 1\n  b: 2\n-\n  a: 3\n- # comment\n  a: 4\n- []\n- {}\n",
+			[]interface{}{
+				map[string]interface{}{"a": int64(1), "b": int64(2)},
+				map[string]interface{}{"a": int64(3)},
+				map[string]interface{}{"a": int64(4)},
+				[]interface{}{},
+				map[string]interface{}{},
+			},
+		},
+		{
+			"nested sequences",
+			"- - a\n  - b\n- - c\n",
+			[]interface{}{[]interface{}{"a", "b"}, []interface{}{"c"}},
+		},
+		{"windows line endings", "a: 1\r\nb: 2\r\n", map[string]interface{}{"a": int64(1), "b": int64(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unmarshalYAML([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalYAML_Unsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"flow sequence", "a: [1, 2]", `line 1: unsupported syntax "[1, 2]"`},
+		{"flow mapping", "a:\n  b: {c: 1}", `line 2: unsupported syntax "{c: 1}"`},
+		{"literal block", "a: |\n  text", `line 1: unsupported syntax "|"`},
+		{"folded block", "a: >-\n  text", `line 1: unsupported syntax ">-"`},
+		{"anchor", "a: &x 1", `unsupported syntax "&x 1"`},
+		{"alias", "- *x", `unsupported syntax "*x"`},
+		{"tag", "a: !!str 1", `unsupported syntax "!!str 1"`},
+		{"directive", "%YAML 1.2\n---\na: 1", `line 1: unsupported syntax`},
+		{"nested key in value", "a: b: c", `unsupported syntax "b: c"`},
+		{"multi-line plain scalar", "a: b\n  c", "line 2: unexpected indentation"},
+		{"tab indentation", "a:\n\tb: 1", "line 2: tabs are not allowed"},
+		{"unterminated quote", `a: "b`, "line 1: unterminated quoted string"},
+		{"text after quote", `a: "b" c`, `line 1: unexpected "c" after quoted string`},
+		{"invalid escape", `a: "\q"`, "line 1: invalid escape"},
+		{"duplicate key", "a: 1\nb: 2\na: 3", `line 3: duplicate key "a"`},
+		{"scalar in mapping", "a: 1\nb", "line 2: expected key: value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := unmarshalYAML([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %#v, %v", tt.want, v, err)
+			}
+		})
+	}
+}
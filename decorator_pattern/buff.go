@@ -0,0 +1,389 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrInvalidBuff = errors.New("invalid buff")
+
+// StackRule decides what applying a buff that is already active does
+type StackRule int
+
+const (
+	// Refresh restarts the duration and the cast count
+	Refresh StackRule = iota
+
+	// Extend adds the duration and the casts to what is left
+	Extend
+
+	// Additive adds a stack, up to MaxStacks, and restarts the duration
+	// and the cast count. Every stack applies the effect once more
+	Additive
+)
+
+// BuffSpec describes a buff built from a registered decorator
+type BuffSpec struct {
+	// Name is the registry name of the decorator that gives the effect
+	Name string
+
+	// Duration limits how long the buff lasts, 0 means no time limit
+	Duration time.Duration
+
+	// Casts limits how many casts the buff lasts, 0 means no cast limit
+	Casts int
+
+	Rule StackRule
+
+	// MaxStacks caps Additive stacking, 0 means no cap
+	MaxStacks int
+}
+
+type BuffEventType int
+
+const (
+	BuffApplied BuffEventType = iota
+	BuffExpired
+	BuffRemoved
+)
+
+func (t BuffEventType) String() string {
+	switch t {
+	case BuffApplied:
+		return "applied"
+	case BuffExpired:
+		return "expired"
+	case BuffRemoved:
+		return "removed"
+	}
+	return fmt.Sprintf("BuffEventType(%d)", int(t))
+}
+
+// BuffEvent is emitted when a buff is applied or stacked, when it runs
+// out and when it is dispelled
+type BuffEvent struct {
+	Type   BuffEventType
+	Name   string
+	Stacks int
+	At     time.Time
+}
+
+// BuffStatus describes an active buff
+type BuffStatus struct {
+	Name      string
+	Stacks    int
+	Remaining time.Duration
+	CastsLeft int
+}
+
+// TimedBuff is a decorator that applies an effect decorator once per
+// stack, until its duration or its casts run out
+type TimedBuff struct {
+	player  Player
+	spec    BuffSpec
+	factory func(player Player) Decorator
+
+	stacks    int
+	expiresAt time.Time
+	castsLeft int
+
+	// effect is the stack of effect decorators around player
+	effect Player
+}
+
+func (t *TimedBuff) ChooseClass(class string) {
+	t.player.ChooseClass(class)
+}
+
+// CastSkill does not use up a cast, decorators such as haste may call it
+// several times for one cast. BuffedPlayer counts the casts instead
+func (t *TimedBuff) CastSkill() string {
+	return t.effect.CastSkill()
+}
+
+func (t *TimedBuff) Name() string   { return t.spec.Name }
+func (t *TimedBuff) Unwrap() Player { return t.player }
+
+func (t *TimedBuff) Wrap(player Player) {
+	t.player = player
+	t.rebuild()
+}
+
+func (t *TimedBuff) rebuild() {
+	t.effect = t.player
+	for i := 0; i < t.stacks; i++ {
+		t.effect = t.factory(t.effect)
+	}
+}
+
+// restart sets the duration and the casts as if the buff was new
+func (t *TimedBuff) restart(now time.Time) {
+	if t.spec.Duration > 0 {
+		t.expiresAt = now.Add(t.spec.Duration)
+	}
+	t.castsLeft = t.spec.Casts
+}
+
+func (t *TimedBuff) stack(now time.Time) {
+	switch t.spec.Rule {
+	case Refresh:
+		t.restart(now)
+	case Extend:
+		if t.spec.Duration > 0 {
+			if t.expiresAt.Before(now) {
+				t.expiresAt = now
+			}
+			t.expiresAt = t.expiresAt.Add(t.spec.Duration)
+		}
+		t.castsLeft += t.spec.Casts
+	case Additive:
+		if t.spec.MaxStacks <= 0 || t.stacks < t.spec.MaxStacks {
+			t.stacks++
+			t.rebuild()
+		}
+		t.restart(now)
+	}
+}
+
+// expiredAt reports whether the buff has run out and since when
+func (t *TimedBuff) expiredAt(now time.Time) (time.Time, bool) {
+	if t.spec.Duration > 0 && !now.Before(t.expiresAt) {
+		return t.expiresAt, true
+	}
+	if t.spec.Casts > 0 && t.castsLeft <= 0 {
+		return now, true
+	}
+	return time.Time{}, false
+}
+
+func (t *TimedBuff) status(now time.Time) BuffStatus {
+	status := BuffStatus{Name: t.spec.Name, Stacks: t.stacks, CastsLeft: t.castsLeft}
+	if t.spec.Duration > 0 {
+		status.Remaining = t.expiresAt.Sub(now)
+	}
+	return status
+}
+
+// BuffedPlayer sits on top of a decorator stack and manages timed buffs
+// below it. Expired buffs take themselves out of the stack whenever the
+// player is used, or when Update is called from the game loop
+type BuffedPlayer struct {
+	mu        sync.Mutex
+	player    Player
+	registry  *Registry
+	clock     Clock
+	listeners []func(BuffEvent)
+}
+
+func NewBuffedPlayer(player Player, registry *Registry, clock Clock) *BuffedPlayer {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &BuffedPlayer{player: player, registry: registry, clock: clock}
+}
+
+// OnBuffEvent registers a listener, it is called without any lock held
+func (b *BuffedPlayer) OnBuffEvent(listener func(BuffEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, listener)
+}
+
+func (b *BuffedPlayer) ChooseClass(class string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.player.ChooseClass(class)
+}
+
+// CastSkill drops the buffs that ran out, casts, uses up one cast of
+// every cast-limited buff and then drops the buffs whose last cast this
+// was
+func (b *BuffedPlayer) CastSkill() string {
+	b.mu.Lock()
+	now := b.clock.Now()
+	events := b.expire(now)
+	skill := b.player.CastSkill()
+	b.cast()
+	events = append(events, b.expire(now)...)
+	b.mu.Unlock()
+
+	b.emit(events)
+	return skill
+}
+
+func (b *BuffedPlayer) Name() string { return "buffs" }
+
+func (b *BuffedPlayer) Unwrap() Player {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.player
+}
+
+func (b *BuffedPlayer) Wrap(player Player) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.player = player
+}
+
+// Apply adds the buff, or stacks it by its rule if it is already active
+func (b *BuffedPlayer) Apply(spec BuffSpec) error {
+	if spec.Duration < 0 || spec.Casts < 0 || spec.MaxStacks < 0 {
+		return fmt.Errorf("%w: %+v", ErrInvalidBuff, spec)
+	}
+	factory, ok := b.registry.factories[spec.Name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownDecorator, spec.Name)
+	}
+
+	b.mu.Lock()
+	now := b.clock.Now()
+	events := b.expire(now)
+	t := b.find(spec.Name)
+	if t != nil {
+		t.spec = spec
+		t.stack(now)
+	} else {
+		t = &TimedBuff{spec: spec, factory: factory, stacks: 1}
+		t.restart(now)
+		t.Wrap(b.player)
+		b.player = t
+	}
+	events = append(events, BuffEvent{Type: BuffApplied, Name: spec.Name, Stacks: t.stacks, At: now})
+	b.mu.Unlock()
+
+	b.emit(events)
+	return nil
+}
+
+// Dispel removes an active buff before it runs out
+func (b *BuffedPlayer) Dispel(name string) bool {
+	b.mu.Lock()
+	now := b.clock.Now()
+	events := b.expire(now)
+	t := b.find(name)
+	if t != nil {
+		b.unlink(t)
+		events = append(events, BuffEvent{Type: BuffRemoved, Name: name, Stacks: t.stacks, At: now})
+	}
+	b.mu.Unlock()
+
+	b.emit(events)
+	return t != nil
+}
+
+// Update drops the buffs that ran out, call it from the game loop to get
+// expiry events for players that are not casting
+func (b *BuffedPlayer) Update() {
+	b.mu.Lock()
+	events := b.expire(b.clock.Now())
+	b.mu.Unlock()
+	b.emit(events)
+}
+
+// Buffs lists the active buffs from the outermost to the innermost
+func (b *BuffedPlayer) Buffs() []BuffStatus {
+	b.mu.Lock()
+	now := b.clock.Now()
+	events := b.expire(now)
+	var buffs []BuffStatus
+	for current := b.player; ; {
+		d, ok := current.(Decorator)
+		if !ok {
+			break
+		}
+		if t, ok := d.(*TimedBuff); ok {
+			buffs = append(buffs, t.status(now))
+		}
+		current = d.Unwrap()
+	}
+	b.mu.Unlock()
+
+	b.emit(events)
+	return buffs
+}
+
+func (b *BuffedPlayer) cast() {
+	for current := b.player; ; {
+		d, ok := current.(Decorator)
+		if !ok {
+			return
+		}
+		if t, ok := d.(*TimedBuff); ok && t.spec.Casts > 0 {
+			t.castsLeft--
+		}
+		current = d.Unwrap()
+	}
+}
+
+func (b *BuffedPlayer) find(name string) *TimedBuff {
+	for current := b.player; ; {
+		d, ok := current.(Decorator)
+		if !ok {
+			return nil
+		}
+		if t, ok := d.(*TimedBuff); ok && t.spec.Name == name {
+			return t
+		}
+		current = d.Unwrap()
+	}
+}
+
+// unlink takes t out of the stack wherever it is
+func (b *BuffedPlayer) unlink(t *TimedBuff) {
+	if b.player == Player(t) {
+		b.player = t.Unwrap()
+		return
+	}
+	for current := b.player; ; {
+		d, ok := current.(Decorator)
+		if !ok {
+			return
+		}
+		if d.Unwrap() == Player(t) {
+			d.Wrap(t.Unwrap())
+			return
+		}
+		current = d.Unwrap()
+	}
+}
+
+// expire unlinks the buffs that ran out and reports them in the order
+// they expired
+func (b *BuffedPlayer) expire(now time.Time) []BuffEvent {
+	var events []BuffEvent
+	for current := b.player; ; {
+		d, ok := current.(Decorator)
+		if !ok {
+			sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+			return events
+		}
+		current = d.Unwrap()
+		t, ok := d.(*TimedBuff)
+		if !ok {
+			continue
+		}
+		if at, expired := t.expiredAt(now); expired {
+			b.unlink(t)
+			events = append(events, BuffEvent{Type: BuffExpired, Name: t.spec.Name, Stacks: t.stacks, At: at})
+		}
+	}
+}
+
+func (b *BuffedPlayer) emit(events []BuffEvent) {
+	if len(events) == 0 {
+		return
+	}
+	b.mu.Lock()
+	listeners := make([]func(BuffEvent), len(b.listeners))
+	copy(listeners, b.listeners)
+	b.mu.Unlock()
+
+	for _, e := range events {
+		for _, listener := range listeners {
+			listener(e)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newBuffed() (*BuffedPlayer, *GameClock, *[]BuffEvent) {
+	clock := NewGameClock(epoch)
+	b := NewBuffedPlayer(&BasicPlayer{}, DefaultRegistry(), clock)
+	var events []BuffEvent
+	b.OnBuffEvent(func(e BuffEvent) { events = append(events, e) })
+	return b, clock, &events
+}
+
+func TestBuffedPlayer_Expiry(t *testing.T) {
+	b, clock, events := newBuffed()
+	if err := b.Apply(BuffSpec{Name: "fire", Duration: 10 * time.Second}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	clock.Advance(9 * time.Second)
+	if got := b.CastSkill(); got != "basic class, fireball" {
+		t.Fatalf("expected the buff before it expires, got %q", got)
+	}
+	clock.Advance(5 * time.Second)
+	if got := b.CastSkill(); got != "basic class" {
+		t.Fatalf("expected the buff to be gone, got %q", got)
+	}
+
+	want := []BuffEvent{
+		{Type: BuffApplied, Name: "fire", Stacks: 1, At: epoch},
+		{Type: BuffExpired, Name: "fire", Stacks: 1, At: epoch.Add(10 * time.Second)},
+	}
+	if !reflect.DeepEqual(*events, want) {
+		t.Fatalf("expected events %v, got %v", want, *events)
+	}
+}
+
+func TestBuffedPlayer_CastLimit(t *testing.T) {
+	b, _, events := newBuffed()
+	if err := b.Apply(BuffSpec{Name: "ice", Casts: 2}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	skills := []string{b.CastSkill(), b.CastSkill(), b.CastSkill()}
+	want := []string{"basic class, frostbolt", "basic class, frostbolt", "basic class"}
+	if !reflect.DeepEqual(skills, want) {
+		t.Fatalf("expected %q, got %q", want, skills)
+	}
+	// the buff is dropped right after its last cast, not on the next one
+	if len(*events) != 2 || (*events)[1].Type != BuffExpired {
+		t.Fatalf("expected applied and expired events, got %v", *events)
+	}
+}
+
+func TestBuffedPlayer_CastLimitUnderHaste(t *testing.T) {
+	b, _, _ := newBuffed()
+	if err := b.Apply(BuffSpec{Name: "ice", Casts: 2}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	// haste ends up outside ice and casts through it twice per cast
+	if err := b.Apply(BuffSpec{Name: "haste"}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	skills := []string{b.CastSkill(), b.CastSkill(), b.CastSkill()}
+	want := []string{
+		"basic class, frostbolt | basic class, frostbolt",
+		"basic class, frostbolt | basic class, frostbolt",
+		"basic class | basic class",
+	}
+	if !reflect.DeepEqual(skills, want) {
+		t.Fatalf("expected %q, got %q", want, skills)
+	}
+}
+
+func TestBuffedPlayer_Stacking(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  BuffSpec
+		times int
+		want  BuffStatus
+		skill string
+	}{
+		{
+			name:  "refresh",
+			spec:  BuffSpec{Name: "fire", Duration: 10 * time.Second, Casts: 3, Rule: Refresh},
+			times: 2,
+			want:  BuffStatus{Name: "fire", Stacks: 1, Remaining: 10 * time.Second, CastsLeft: 3},
+			skill: "basic class, fireball",
+		},
+		{
+			name:  "extend",
+			spec:  BuffSpec{Name: "fire", Duration: 10 * time.Second, Casts: 3, Rule: Extend},
+			times: 2,
+			want:  BuffStatus{Name: "fire", Stacks: 1, Remaining: 16 * time.Second, CastsLeft: 6},
+			skill: "basic class, fireball",
+		},
+		{
+			name:  "additive",
+			spec:  BuffSpec{Name: "fire", Duration: 10 * time.Second, Rule: Additive},
+			times: 3,
+			want:  BuffStatus{Name: "fire", Stacks: 3, Remaining: 10 * time.Second},
+			skill: "basic class, fireball, fireball, fireball",
+		},
+		{
+			name:  "additive capped",
+			spec:  BuffSpec{Name: "fire", Duration: 10 * time.Second, Rule: Additive, MaxStacks: 2},
+			times: 3,
+			want:  BuffStatus{Name: "fire", Stacks: 2, Remaining: 10 * time.Second},
+			skill: "basic class, fireball, fireball",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, clock, events := newBuffed()
+			for i := 0; i < tt.times; i++ {
+				if i > 0 {
+					clock.Advance(4 * time.Second)
+				}
+				if err := b.Apply(tt.spec); err != nil {
+					t.Fatalf("apply: %v", err)
+				}
+			}
+			if got := b.Buffs(); !reflect.DeepEqual(got, []BuffStatus{tt.want}) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+			if got := b.CastSkill(); got != tt.skill {
+				t.Fatalf("expected %q, got %q", tt.skill, got)
+			}
+			if last := (*events)[len(*events)-1]; last.Type != BuffApplied || last.Stacks != tt.want.Stacks {
+				t.Fatalf("expected an applied event with %d stacks, got %v", tt.want.Stacks, last)
+			}
+		})
+	}
+}
+
+func TestBuffedPlayer_ExtendAfterExpiry(t *testing.T) {
+	b, clock, _ := newBuffed()
+	spec := BuffSpec{Name: "fire", Duration: 10 * time.Second, Rule: Extend}
+	_ = b.Apply(spec)
+	clock.Advance(15 * time.Second)
+	_ = b.Apply(spec)
+
+	// the expired buff is dropped, so it starts over instead of extending
+	if got := b.Buffs(); len(got) != 1 || got[0].Remaining != 10*time.Second {
+		t.Fatalf("expected a fresh buff, got %+v", got)
+	}
+}
+
+func TestBuffedPlayer_UpdateOrdersExpiry(t *testing.T) {
+	b, clock, events := newBuffed()
+	// the outer buff expires first
+	_ = b.Apply(BuffSpec{Name: "ice", Duration: 20 * time.Second})
+	_ = b.Apply(BuffSpec{Name: "fire", Duration: 5 * time.Second})
+	*events = nil
+
+	clock.Advance(30 * time.Second)
+	b.Update()
+	want := []BuffEvent{
+		{Type: BuffExpired, Name: "fire", Stacks: 1, At: epoch.Add(5 * time.Second)},
+		{Type: BuffExpired, Name: "ice", Stacks: 1, At: epoch.Add(20 * time.Second)},
+	}
+	if !reflect.DeepEqual(*events, want) {
+		t.Fatalf("expected events %v, got %v", want, *events)
+	}
+	if got := b.CastSkill(); got != "basic class" {
+		t.Fatalf("expected no buffs left, got %q", got)
+	}
+}
+
+func TestBuffedPlayer_Dispel(t *testing.T) {
+	b, _, events := newBuffed()
+	_ = b.Apply(BuffSpec{Name: "fire"})
+	_ = b.Apply(BuffSpec{Name: "ice"})
+	_ = b.Apply(BuffSpec{Name: "shield"})
+
+	if !b.Dispel("ice") {
+		t.Fatalf("expected ice to be dispelled")
+	}
+	if b.Dispel("ice") {
+		t.Fatalf("expected a second dispel to fail")
+	}
+	if got := b.CastSkill(); got != "shield up, basic class, fireball" {
+		t.Fatalf("unexpected skill %q", got)
+	}
+	if last := (*events)[len(*events)-1]; last.Type != BuffRemoved || last.Name != "ice" {
+		t.Fatalf("expected a removed event, got %v", last)
+	}
+}
+
+func TestBuffedPlayer_ApplyErrors(t *testing.T) {
+	tests := []struct {
+		spec BuffSpec
+		want error
+	}{
+		{BuffSpec{Name: "fire", Duration: -time.Second}, ErrInvalidBuff},
+		{BuffSpec{Name: "fire", Casts: -1}, ErrInvalidBuff},
+		{BuffSpec{Name: "fire", MaxStacks: -1}, ErrInvalidBuff},
+		{BuffSpec{Name: "lightning"}, ErrUnknownDecorator},
+	}
+	for _, tt := range tests {
+		b, _, events := newBuffed()
+		if err := b.Apply(tt.spec); !errors.Is(err, tt.want) {
+			t.Fatalf("%+v: expected %v, got %v", tt.spec, tt.want, err)
+		}
+		if len(*events) != 0 || len(b.Buffs()) != 0 {
+			t.Fatalf("%+v: expected nothing applied", tt.spec)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells buffs what time it is in the game
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock follows the wall clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// GameClock only moves when the game advances it, which keeps buff
+// expiry deterministic in tests and replays
+type GameClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewGameClock(start time.Time) *GameClock {
+	return &GameClock{now: start}
+}
+
+func (c *GameClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *GameClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
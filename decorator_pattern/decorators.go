@@ -0,0 +1,70 @@
+package main
+
+// IcePlayer adds a frost skill
+type IcePlayer struct {
+	player Player
+}
+
+func (i *IcePlayer) ChooseClass(class string) {
+	i.player.ChooseClass(class)
+}
+
+func (i *IcePlayer) CastSkill() string {
+	return i.player.CastSkill() + ", frostbolt"
+}
+
+func (i *IcePlayer) Name() string       { return "ice" }
+func (i *IcePlayer) Unwrap() Player     { return i.player }
+func (i *IcePlayer) Wrap(player Player) { i.player = player }
+
+// PoisonPlayer adds a damage over time skill
+type PoisonPlayer struct {
+	player Player
+}
+
+func (p *PoisonPlayer) ChooseClass(class string) {
+	p.player.ChooseClass(class)
+}
+
+func (p *PoisonPlayer) CastSkill() string {
+	return p.player.CastSkill() + ", poison cloud"
+}
+
+func (p *PoisonPlayer) Name() string       { return "poison" }
+func (p *PoisonPlayer) Unwrap() Player     { return p.player }
+func (p *PoisonPlayer) Wrap(player Player) { p.player = player }
+
+// HastePlayer casts everything below it twice
+type HastePlayer struct {
+	player Player
+}
+
+func (h *HastePlayer) ChooseClass(class string) {
+	h.player.ChooseClass(class)
+}
+
+func (h *HastePlayer) CastSkill() string {
+	skill := h.player.CastSkill()
+	return skill + " | " + skill
+}
+
+func (h *HastePlayer) Name() string       { return "haste" }
+func (h *HastePlayer) Unwrap() Player     { return h.player }
+func (h *HastePlayer) Wrap(player Player) { h.player = player }
+
+// ShieldPlayer raises a shield before casting
+type ShieldPlayer struct {
+	player Player
+}
+
+func (s *ShieldPlayer) ChooseClass(class string) {
+	s.player.ChooseClass(class)
+}
+
+func (s *ShieldPlayer) CastSkill() string {
+	return "shield up, " + s.player.CastSkill()
+}
+
+func (s *ShieldPlayer) Name() string       { return "shield" }
+func (s *ShieldPlayer) Unwrap() Player     { return s.player }
+func (s *ShieldPlayer) Wrap(player Player) { s.player = player }
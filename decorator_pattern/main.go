@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 )
 
 func main() {
@@ -12,4 +13,53 @@ func main() {
 	firePlayer.ChooseClass("mage")
 	skill := firePlayer.CastSkill()
 	fmt.Println(skill)
+
+	// build a stack from config
+	registry := DefaultRegistry()
+	stacked, err := registry.Build(&BasicPlayer{}, []string{"fire", "ice", "haste", "shield"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(Layers(stacked), "->", stacked.CastSkill())
+
+	// the ice buff is dispelled from the middle of the stack
+	stacked, _ = Remove(stacked, "ice")
+	fmt.Println(Layers(stacked), "->", stacked.CastSkill())
+
+	// and the shield on top breaks
+	stacked, _ = Remove(stacked, "shield")
+	fmt.Println(Layers(stacked), "->", stacked.CastSkill())
+
+	if _, err := registry.Build(&BasicPlayer{}, []string{"fire", "lightning"}); err != nil {
+		fmt.Println(err)
+	}
+
+	buffs()
+}
+
+func buffs() {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewGameClock(start)
+	registry := DefaultRegistry()
+
+	player := NewBuffedPlayer(&FirePlayer{player: &BasicPlayer{}}, registry, clock)
+	player.OnBuffEvent(func(e BuffEvent) {
+		fmt.Printf("[%v] %s %s x%d\n", e.At.Sub(start), e.Name, e.Type, e.Stacks)
+	})
+
+	player.Apply(BuffSpec{Name: "haste", Duration: 10 * time.Second, Rule: Refresh})
+	player.Apply(BuffSpec{Name: "ice", Casts: 2, Rule: Extend})
+	fmt.Println(player.CastSkill())
+
+	clock.Advance(4 * time.Second)
+	player.Apply(BuffSpec{Name: "poison", Duration: 5 * time.Second, Rule: Additive, MaxStacks: 2})
+	player.Apply(BuffSpec{Name: "poison", Duration: 5 * time.Second, Rule: Additive, MaxStacks: 2})
+	player.Apply(BuffSpec{Name: "poison", Duration: 5 * time.Second, Rule: Additive, MaxStacks: 2})
+	fmt.Println(player.CastSkill())
+	fmt.Printf("%+v\n", player.Buffs())
+
+	clock.Advance(6 * time.Second)
+	player.Update()
+	fmt.Println(player.CastSkill())
 }
@@ -6,6 +6,21 @@ type Player interface {
 	CastSkill() string
 }
 
+// Decorator is a Player that wraps another Player, it lets a stack of
+// decorators be inspected and changed at runtime
+type Decorator interface {
+	Player
+
+	// Name is the registry name of the decorator
+	Name() string
+
+	// Unwrap returns the wrapped Player
+	Unwrap() Player
+
+	// Wrap replaces the wrapped Player
+	Wrap(player Player)
+}
+
 // BasicPlayer is the concrete who implements the Player interface
 type BasicPlayer struct {
 	Class string
@@ -31,3 +46,7 @@ func (f *FirePlayer) ChooseClass(class string) {
 func (f *FirePlayer) CastSkill() string {
 	return f.player.CastSkill() + ", fireball"
 }
+
+func (f *FirePlayer) Name() string       { return "fire" }
+func (f *FirePlayer) Unwrap() Player     { return f.player }
+func (f *FirePlayer) Wrap(player Player) { f.player = player }
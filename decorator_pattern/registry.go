@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrUnknownDecorator = errors.New("unknown decorator")
+
+// Registry creates decorators by name, so a stack can be described in
+// config such as ["fire", "ice"]
+type Registry struct {
+	factories map[string]func(player Player) Decorator
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]func(player Player) Decorator)}
+}
+
+// DefaultRegistry knows every decorator of this package
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("fire", func(p Player) Decorator { return &FirePlayer{player: p} })
+	r.Register("ice", func(p Player) Decorator { return &IcePlayer{player: p} })
+	r.Register("poison", func(p Player) Decorator { return &PoisonPlayer{player: p} })
+	r.Register("haste", func(p Player) Decorator { return &HastePlayer{player: p} })
+	r.Register("shield", func(p Player) Decorator { return &ShieldPlayer{player: p} })
+	return r
+}
+
+func (r *Registry) Register(name string, factory func(player Player) Decorator) {
+	r.factories[name] = factory
+}
+
+// Names returns the registered names in alphabetical order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Decorate wraps player with one decorator
+func (r *Registry) Decorate(player Player, name string) (Player, error) {
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDecorator, name)
+	}
+	return factory(player), nil
+}
+
+// Build wraps player with the named decorators, the first name is the
+// innermost layer
+func (r *Registry) Build(player Player, names []string) (Player, error) {
+	for _, name := range names {
+		var err error
+		if player, err = r.Decorate(player, name); err != nil {
+			return nil, err
+		}
+	}
+	return player, nil
+}
+
+// Layers lists the decorators wrapping player from the innermost to the
+// outermost, the same order Build takes
+func Layers(player Player) []string {
+	var names []string
+	for {
+		d, ok := player.(Decorator)
+		if !ok {
+			break
+		}
+		names = append(names, d.Name())
+		player = d.Unwrap()
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names
+}
+
+// Base returns the Player under every decorator
+func Base(player Player) Player {
+	for {
+		d, ok := player.(Decorator)
+		if !ok {
+			return player
+		}
+		player = d.Unwrap()
+	}
+}
+
+// Remove takes the outermost decorator called name out of the stack and
+// returns the new top of the stack, which is only different from player
+// when the top itself was removed
+func Remove(player Player, name string) (Player, bool) {
+	var outer Decorator
+	for current := player; ; {
+		d, ok := current.(Decorator)
+		if !ok {
+			return player, false
+		}
+		if d.Name() == name {
+			if outer == nil {
+				return d.Unwrap(), true
+			}
+			outer.Wrap(d.Unwrap())
+			return player, true
+		}
+		outer, current = d, d.Unwrap()
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry_Build(t *testing.T) {
+	tests := []struct {
+		names []string
+		skill string
+	}{
+		{nil, "basic class"},
+		{[]string{"fire"}, "basic class, fireball"},
+		{[]string{"fire", "ice"}, "basic class, fireball, frostbolt"},
+		{[]string{"poison", "haste"}, "basic class, poison cloud | basic class, poison cloud"},
+		{[]string{"haste", "poison"}, "basic class | basic class, poison cloud"},
+		{[]string{"fire", "shield"}, "shield up, basic class, fireball"},
+	}
+	registry := DefaultRegistry()
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.names), func(t *testing.T) {
+			base := &BasicPlayer{}
+			player, err := registry.Build(base, tt.names)
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			if got := player.CastSkill(); got != tt.skill {
+				t.Fatalf("expected %q, got %q", tt.skill, got)
+			}
+			if got := Layers(player); !reflect.DeepEqual(got, tt.names) {
+				t.Fatalf("expected layers %v, got %v", tt.names, got)
+			}
+			if Base(player) != Player(base) {
+				t.Fatalf("expected the base player at the bottom of the stack")
+			}
+
+			// every layer forwards ChooseClass to the base
+			player.ChooseClass("mage")
+			if base.Class != "mage" {
+				t.Fatalf("expected class to reach the base, got %q", base.Class)
+			}
+		})
+	}
+
+	if _, err := registry.Build(&BasicPlayer{}, []string{"fire", "lightning"}); !errors.Is(err, ErrUnknownDecorator) {
+		t.Fatalf("expected ErrUnknownDecorator, got %v", err)
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"fire", "haste", "ice", "poison", "shield"}) {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name    string
+		stack   []string
+		remove  string
+		want    []string
+		removed bool
+	}{
+		{"top", []string{"fire", "ice"}, "ice", []string{"fire"}, true},
+		{"middle", []string{"fire", "ice", "haste"}, "ice", []string{"fire", "haste"}, true},
+		{"bottom", []string{"fire", "ice"}, "fire", []string{"ice"}, true},
+		{"outermost of two", []string{"fire", "ice", "fire"}, "fire", []string{"fire", "ice"}, true},
+		{"missing", []string{"fire"}, "ice", []string{"fire"}, false},
+		{"undecorated", nil, "fire", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player, _ := DefaultRegistry().Build(&BasicPlayer{}, tt.stack)
+			player, removed := Remove(player, tt.remove)
+			if removed != tt.removed || !reflect.DeepEqual(Layers(player), tt.want) {
+				t.Fatalf("expected %v (removed %v), got %v (removed %v)", tt.want, tt.removed, Layers(player), removed)
+			}
+		})
+	}
+}
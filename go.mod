@@ -1,3 +1,3 @@
 module github.com/hedon954/go-designmode
 
-go 1.18
+go 1.21
@@ -2,41 +2,24 @@ package main
 
 import (
 	"fmt"
+
+	"github.com/hedon954/go-designmode/observer_pattern/observer"
 )
 
 func main() {
-	sub := &SubjectImpl{}
-	sub.Subscribe(&ObserverImpl1{})
-	sub.Subscribe(&ObserverImpl2{})
+	sub := observer.NewSubject[string]()
+	o1 := &ObserverImpl1{}
+	sub.Subscribe(o1)
+	sub.Subscribe(&ObserverImpl2{}, observer.WithPriority(1))
 	sub.Notify("hello")
-}
-
-// Subject 发布者
-type Subject interface {
-	Subscribe(observer Observer)
-	Notify(msg string)
-}
-
-// Observer 订阅者
-type Observer interface {
-	Update(msg string)
-}
-
-// SubjectImpl 实现 Subject 接口
-type SubjectImpl struct {
-	observers []Observer
-}
-
-// Subscribe 添加观察者
-func (s *SubjectImpl) Subscribe(observer Observer) {
-	s.observers = append(s.observers, observer)
-}
 
-// Notify 发布通知
-func (s *SubjectImpl) Notify(msg string) {
-	for _, o := range s.observers {
-		o.Update(msg)
-	}
+	// 取消订阅后不再收到通知
+	sub.Unsubscribe(o1)
+	s := sub.Subscribe(observer.ObserverFunc[string](func(msg string) {
+		fmt.Printf("ObserverFunc updated: %s\n", msg)
+	}))
+	sub.Notify("world")
+	s.Unsubscribe()
 }
 
 // ObserverImpl1 实现 Obsever 接口
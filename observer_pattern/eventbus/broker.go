@@ -0,0 +1,204 @@
+package eventbus
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// peer 连接到 Broker 的一个 RemoteBus
+type peer struct {
+	conn *frameConn
+	subs map[string]*subscriber // 按订阅主题去重，subscriber 只用于在前缀树中匹配
+}
+
+// Broker 在多个进程的 RemoteBus 之间转发事件
+//
+// RemoteBus 发布的事件会转发给所有订阅了匹配主题的 RemoteBus（包括发布者自己），
+// 主题匹配规则与 AsyncEventBus 相同。
+type Broker struct {
+	codec     Codec
+	heartbeat heartbeatConfig
+
+	lock      sync.Mutex
+	listeners map[net.Listener]struct{}
+	peers     map[*peer]struct{}
+	routes    *topicTrie
+	owners    map[*subscriber]*peer
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewBroker 创建 Broker，WithCodec 需要与连接的 RemoteBus 一致
+func NewBroker(options ...*Option) *Broker {
+	opts := defaultBusOptions()
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	return &Broker{
+		codec:     opts.codec,
+		heartbeat: opts.heartbeat,
+		listeners: map[net.Listener]struct{}{},
+		peers:     map[*peer]struct{}{},
+		routes:    newTopicTrie(),
+		owners:    map[*subscriber]*peer{},
+	}
+}
+
+// ListenAndServe 监听 network/addr 并处理连接，network 可以是 "tcp" 或 "unix"
+func (b *Broker) ListenAndServe(network, addr string) error {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return b.Serve(l)
+}
+
+// Serve 在 l 上接受连接，直到 l 出错或 Broker 关闭
+func (b *Broker) Serve(l net.Listener) error {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		l.Close()
+		return ErrBusClosed
+	}
+	b.listeners[l] = struct{}{}
+	b.lock.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			b.lock.Lock()
+			closed := b.closed
+			delete(b.listeners, l)
+			b.lock.Unlock()
+			if closed {
+				return ErrBusClosed
+			}
+			return err
+		}
+
+		p := &peer{
+			conn: newFrameConn(conn, b.codec, b.heartbeat.timeout()),
+			subs: map[string]*subscriber{},
+		}
+		b.lock.Lock()
+		if b.closed {
+			b.lock.Unlock()
+			conn.Close()
+			return ErrBusClosed
+		}
+		b.peers[p] = struct{}{}
+		b.wg.Add(1)
+		b.lock.Unlock()
+
+		go b.serve(p)
+	}
+}
+
+// serve 处理一个连接上的帧，直到连接断开
+func (b *Broker) serve(p *peer) {
+	defer b.wg.Done()
+	defer b.removePeer(p)
+
+	for {
+		typ, msg, err := p.conn.read()
+		if err != nil {
+			return
+		}
+
+		switch typ {
+		case framePing:
+			err = p.conn.write(framePong, nil)
+		case frameSubscribe:
+			b.subscribe(p, msg.Topic)
+		case frameUnsubscribe:
+			b.unsubscribe(p, msg.Topic)
+		case framePublish:
+			b.forward(msg)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (b *Broker) subscribe(p *peer, topic string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := p.subs[topic]; ok {
+		return
+	}
+	sub := &subscriber{topic: topic}
+	p.subs[topic] = sub
+	b.owners[sub] = p
+	b.routes.add(sub)
+}
+
+func (b *Broker) unsubscribe(p *peer, topic string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	sub, ok := p.subs[topic]
+	if !ok {
+		return
+	}
+	delete(p.subs, topic)
+	delete(b.owners, sub)
+	b.routes.remove(sub)
+}
+
+// forward 把事件转发给所有订阅了匹配主题的连接
+func (b *Broker) forward(msg *remoteMessage) {
+	b.lock.Lock()
+	targets := map[*peer]struct{}{}
+	for _, sub := range b.routes.match(msg.Topic) {
+		targets[b.owners[sub]] = struct{}{}
+	}
+	b.lock.Unlock()
+
+	for p := range targets {
+		if err := p.conn.write(framePublish, msg); err != nil {
+			// 写失败的连接直接关闭，读循环随后会清理它的订阅
+			p.conn.close()
+		}
+	}
+}
+
+func (b *Broker) removePeer(p *peer) {
+	p.conn.close()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, sub := range p.subs {
+		delete(b.owners, sub)
+		b.routes.remove(sub)
+	}
+	delete(b.peers, p)
+}
+
+// Close 停止监听并断开所有连接
+func (b *Broker) Close() error {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return nil
+	}
+	b.closed = true
+	var errs []error
+	for l := range b.listeners {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for p := range b.peers {
+		p.conn.close()
+	}
+	b.lock.Unlock()
+
+	b.wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,38 @@
+// walcompact 删除持久化事件总线中所有订阅者都已处理完的日志分段
+//
+// 用法：walcompact -dir ./data/events
+//
+// 请在没有进程打开该目录时运行。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hedon954/go-designmode/observer_pattern/eventbus"
+)
+
+func main() {
+	dir := flag.String("dir", "", "event bus WAL directory")
+	flag.Parse()
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	bus, err := eventbus.NewDurableEventBus(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer bus.Close(context.Background())
+
+	removed, err := bus.Compact()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("removed %d segment(s)\n", removed)
+}
@@ -0,0 +1,95 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// Codec 事件参数的编解码方式，用于需要把事件写出进程的总线实现
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec 使用 encoding/json 编解码
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// encodedArg 编码后的单个事件参数
+//
+// context.Context 无法序列化，只记录一个占位，解码时替换为 context.Background()。
+type encodedArg struct {
+	Context bool   `json:"ctx,omitempty"`
+	Nil     bool   `json:"nil,omitempty"`
+	Data    []byte `json:"data,omitempty"`
+}
+
+// encodeArgs 逐个编码事件参数
+func encodeArgs(codec Codec, args []interface{}) ([]encodedArg, error) {
+	encoded := make([]encodedArg, len(args))
+	for i, arg := range args {
+		switch arg.(type) {
+		case nil:
+			encoded[i].Nil = true
+		case context.Context:
+			encoded[i].Context = true
+		default:
+			data, err := codec.Marshal(arg)
+			if err != nil {
+				return nil, fmt.Errorf("eventbus: encode arg %d: %w", i, err)
+			}
+			encoded[i].Data = data
+		}
+	}
+	return encoded, nil
+}
+
+// decodeArgs 按 handler 的入参类型解码事件参数，多出来的参数解码为 interface{}
+func decodeArgs(codec Codec, fn reflect.Type, encoded []encodedArg) ([]interface{}, error) {
+	args := make([]interface{}, len(encoded))
+	for i, arg := range encoded {
+		if arg.Nil {
+			continue
+		}
+		if arg.Context {
+			args[i] = context.Background()
+			continue
+		}
+
+		in := paramType(fn, i)
+		if in == nil {
+			in = reflect.TypeOf((*interface{})(nil)).Elem()
+		}
+		v := reflect.New(in)
+		if err := codec.Unmarshal(arg.Data, v.Interface()); err != nil {
+			return nil, fmt.Errorf("eventbus: decode arg %d as %s: %w", i, in, err)
+		}
+		args[i] = v.Elem().Interface()
+	}
+	return args, nil
+}
+
+// paramType handler 第 i 个参数的类型，超出参数个数时返回 nil
+func paramType(fn reflect.Type, i int) reflect.Type {
+	numIn := fn.NumIn()
+	if fn.IsVariadic() && i >= numIn-1 {
+		return fn.In(numIn - 1).Elem()
+	}
+	if i < numIn {
+		return fn.In(i)
+	}
+	return nil
+}
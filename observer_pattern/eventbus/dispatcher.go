@@ -0,0 +1,323 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBusClosed 事件总线已经关闭
+var ErrBusClosed = errors.New("eventbus: bus is closed")
+
+// ErrQueueFull 主题队列已满，且溢出策略为 ReturnError
+var ErrQueueFull = errors.New("eventbus: queue is full")
+
+// OverflowPolicy 主题队列满时的处理策略
+type OverflowPolicy int
+
+const (
+	// Block 阻塞发布者，直到队列中有空位
+	Block OverflowPolicy = iota
+	// DropOldest 丢弃队列中最早的事件
+	DropOldest
+	// DropNewest 丢弃当前发布的事件
+	DropNewest
+	// ReturnError 不入队，Publish 返回 ErrQueueFull
+	ReturnError
+)
+
+// TopicConfig 主题队列配置
+type TopicConfig struct {
+	QueueSize int
+	Overflow  OverflowPolicy
+}
+
+// TopicStats 主题队列的运行状态
+type TopicStats struct {
+	// Depth 当前排队等待处理的事件数，不包含正在处理的事件
+	Depth int
+	// Capacity 单个队列的容量
+	Capacity int
+	// Dropped 因队列满而被丢弃的事件数
+	Dropped uint64
+}
+
+// task 一次发布产生的待处理事件
+type task struct {
+	topic string
+	args  []interface{}
+	subs  []*subscriber
+	queue *queue // 事件所在的队列，出队时设置
+}
+
+// queue 一个有界队列
+//
+// 默认每个主题一个队列；设置了分区键时，每个主题的每个分区键一个队列。
+type queue struct {
+	lane  string
+	topic string
+	cfg   TopicConfig
+	items []*task
+	ready bool // 是否已经在 dispatcher.ready 中
+	busy  bool // 有序模式下，是否有事件正在处理
+}
+
+func (q *queue) full() bool {
+	return len(q.items) >= q.cfg.QueueSize
+}
+
+func (q *queue) pop() *task {
+	t := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return t
+}
+
+// dispatcher 固定数量的 worker 轮流从各个队列中取事件执行
+type dispatcher struct {
+	mu       sync.Mutex
+	hasWork  *sync.Cond
+	hasSpace *sync.Cond
+	idle     *sync.Cond
+
+	pending int  // 排队中和执行中的事件数
+	closed  bool // 关闭后不再接受新事件
+
+	defaults  TopicConfig
+	configs   map[string]TopicConfig
+	ordered   bool
+	partition PartitionFunc
+	queues    map[string]*queue
+	ready     []*queue // 有待处理事件的队列，按轮转顺序排列
+	dropped   map[string]uint64
+
+	run func(*task)
+}
+
+func newDispatcher(opts *busOption, run func(*task)) *dispatcher {
+	d := &dispatcher{
+		defaults:  opts.topic,
+		configs:   opts.topics,
+		ordered:   opts.ordered,
+		partition: opts.partition,
+		queues:    map[string]*queue{},
+		dropped:   map[string]uint64{},
+		run:       run,
+	}
+	d.hasWork = sync.NewCond(&d.mu)
+	d.hasSpace = sync.NewCond(&d.mu)
+	d.idle = sync.NewCond(&d.mu)
+	for i := 0; i < opts.workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// configOf 获取主题的队列配置
+func (d *dispatcher) configOf(topic string) TopicConfig {
+	cfg, ok := d.configs[topic]
+	if !ok {
+		cfg = d.defaults
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = d.defaults.QueueSize
+	}
+	return cfg
+}
+
+// laneOf 事件所属队列的名称
+func (d *dispatcher) laneOf(t *task) string {
+	if d.partition == nil {
+		return t.topic
+	}
+	return t.topic + "\x00" + d.partition(t.topic, t.args)
+}
+
+// queueOf 获取事件对应的队列，不存在时按配置创建，调用方需持有锁
+func (d *dispatcher) queueOf(lane, topic string) *queue {
+	q, ok := d.queues[lane]
+	if !ok {
+		q = &queue{lane: lane, topic: topic, cfg: d.configOf(topic)}
+		d.queues[lane] = q
+	}
+	return q
+}
+
+// enqueue 将事件放入队列，队列满时按主题的溢出策略处理
+func (d *dispatcher) enqueue(t *task) error {
+	lane := d.laneOf(t)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return ErrBusClosed
+	}
+
+	q := d.queueOf(lane, t.topic)
+	for q.full() {
+		switch q.cfg.Overflow {
+		case DropOldest:
+			q.pop()
+			d.dropped[t.topic]++
+			d.done()
+		case DropNewest:
+			d.dropped[t.topic]++
+			return nil
+		case ReturnError:
+			return ErrQueueFull
+		default:
+			d.hasSpace.Wait()
+			if d.closed {
+				return ErrBusClosed
+			}
+			// 等待期间队列可能被清空回收，需要重新获取
+			q = d.queueOf(lane, t.topic)
+		}
+	}
+
+	d.pending++
+	q.items = append(q.items, t)
+	d.schedule(q)
+	return nil
+}
+
+// schedule 将有待处理事件的队列放入就绪列表，调用方需持有锁
+func (d *dispatcher) schedule(q *queue) {
+	if q.ready || q.busy || len(q.items) == 0 {
+		return
+	}
+	q.ready = true
+	d.ready = append(d.ready, q)
+	d.hasWork.Signal()
+}
+
+// next 取出下一个待处理事件，没有事件时阻塞；总线关闭且事件处理完后返回 nil
+func (d *dispatcher) next() *task {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for len(d.ready) == 0 {
+		if d.closed {
+			return nil
+		}
+		d.hasWork.Wait()
+	}
+
+	q := d.ready[0]
+	d.ready[0] = nil
+	d.ready = d.ready[1:]
+	q.ready = false
+
+	t := q.pop()
+	t.queue = q
+	if d.ordered {
+		// 有序模式下，当前事件处理完之前，队列中的后续事件不会被其他 worker 取走
+		q.busy = true
+	} else if len(q.items) > 0 {
+		// 放回队尾，避免某个繁忙的主题饿死其他主题
+		d.schedule(q)
+	} else {
+		d.release(q)
+	}
+	d.hasSpace.Broadcast()
+	return t
+}
+
+// finish 一个事件处理完毕
+func (d *dispatcher) finish(t *task) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q := t.queue
+	if q.busy {
+		q.busy = false
+		if len(q.items) > 0 {
+			d.schedule(q)
+		} else {
+			d.release(q)
+		}
+	}
+	d.done()
+}
+
+// release 回收空闲的空队列，避免分区键过多时队列无限增长，调用方需持有锁
+func (d *dispatcher) release(q *queue) {
+	if len(q.items) == 0 && !q.busy && d.queues[q.lane] == q {
+		delete(d.queues, q.lane)
+	}
+}
+
+// done 一个事件处理完毕或被丢弃，调用方需持有锁
+func (d *dispatcher) done() {
+	d.pending--
+	if d.pending == 0 {
+		d.idle.Broadcast()
+	}
+}
+
+func (d *dispatcher) work() {
+	for {
+		t := d.next()
+		if t == nil {
+			return
+		}
+		d.run(t)
+		d.finish(t)
+	}
+}
+
+// isClosed 是否已经关闭
+func (d *dispatcher) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+// close 停止接受新事件，唤醒阻塞中的发布者和空闲的 worker
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	d.hasSpace.Broadcast()
+	d.hasWork.Broadcast()
+}
+
+// wait 等待所有已接受的事件处理完毕，或 ctx 结束
+func (d *dispatcher) wait(ctx context.Context) error {
+	// ctx 结束时唤醒等待者，避免在 Cond 上永远阻塞
+	stop := context.AfterFunc(ctx, func() {
+		d.mu.Lock()
+		d.idle.Broadcast()
+		d.mu.Unlock()
+	})
+	defer stop()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for d.pending > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		d.idle.Wait()
+	}
+	return nil
+}
+
+// stats 获取主题队列状态，设置了分区键时汇总该主题所有分区的队列
+func (d *dispatcher) stats(topic string) TopicStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := TopicStats{
+		Capacity: d.configOf(topic).QueueSize,
+		Dropped:  d.dropped[topic],
+	}
+	for _, q := range d.queues {
+		if q.topic == topic {
+			stats.Depth += len(q.items)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,402 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const offsetsFile = "offsets.json"
+
+// offsetStore 持久化的订阅者消费进度，记录每个订阅者下一条要处理的 offset
+type offsetStore struct {
+	path    string
+	mu      sync.Mutex
+	offsets map[string]uint64
+}
+
+func openOffsetStore(dir string) (*offsetStore, error) {
+	s := &offsetStore{
+		path:    filepath.Join(dir, offsetsFile),
+		offsets: map[string]uint64{},
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.offsets); err != nil {
+		return nil, fmt.Errorf("eventbus: load offsets: %w", err)
+	}
+	return s, nil
+}
+
+func (s *offsetStore) get(name string) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset, ok := s.offsets[name]
+	return offset, ok
+}
+
+// commit 更新订阅者的进度，先写临时文件再重命名，避免写到一半时崩溃
+func (s *offsetStore) commit(name string, offset uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offsets[name] = offset
+	data, err := json.Marshal(s.offsets)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// min 所有订阅者中最小的进度
+func (s *offsetStore) min() (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var min uint64
+	found := false
+	for _, offset := range s.offsets {
+		if !found || offset < min {
+			min, found = offset, true
+		}
+	}
+	return min, found
+}
+
+// consumer 一个订阅者的消费者，在独立的 goroutine 中按日志顺序处理事件
+type consumer struct {
+	bus    *DurableEventBus
+	sub    *subscriber
+	name   string // 持久化订阅者的名称，为空时不记录进度
+	reader *walReader
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (c *consumer) Topic() string {
+	return c.sub.topic
+}
+
+// Unsubscribe 停止消费，持久化订阅者的进度会保留，下次以相同名称订阅时从该进度继续
+func (c *consumer) Unsubscribe() {
+	c.once.Do(func() {
+		close(c.stop)
+		c.bus.removeConsumer(c)
+	})
+}
+
+func (c *consumer) run() {
+	defer close(c.done)
+	defer c.reader.close()
+
+	for {
+		changed := c.bus.changed()
+		rec, err := c.reader.next()
+		if err == errCaughtUp {
+			select {
+			case <-changed:
+				continue
+			case <-c.stop:
+				return
+			}
+		}
+		if err != nil {
+			c.bus.onError(&HandlerError{Topic: c.sub.topic, Err: fmt.Errorf("eventbus: read wal: %w", err)})
+			select {
+			case <-changed:
+				continue
+			case <-c.stop:
+				return
+			}
+		}
+
+		c.handle(rec)
+
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+	}
+}
+
+// handle 处理一条记录并提交进度；处理失败的事件交给 ErrorHandler 后同样视为已处理
+func (c *consumer) handle(rec *walRecord) {
+	if topicMatches(c.sub.topic, rec.Topic) {
+		args, err := decodeArgs(c.bus.codec, c.sub.fn.Type(), rec.Args)
+		if err != nil {
+			c.bus.onError(&HandlerError{Topic: rec.Topic, Err: err})
+		} else if herr := deliver(c.sub, rec.Topic, args); herr != nil {
+			c.bus.onError(herr)
+		}
+	}
+
+	if c.name != "" {
+		if err := c.bus.offsets.commit(c.name, rec.Offset+1); err != nil {
+			c.bus.onError(&HandlerError{Topic: rec.Topic, Err: fmt.Errorf("eventbus: commit offset: %w", err)})
+		}
+	}
+	c.reader.advance(rec)
+	c.bus.progress()
+}
+
+// DurableEventBus 持久化事件总线
+//
+// 每次 Publish 都先追加到本地分段的预写日志（WAL）再返回，每个订阅者在独立的 goroutine 中
+// 按日志顺序消费。通过 WithDurableName 订阅的订阅者会持久化消费进度，
+// 进程重启后以相同名称重新订阅，会从上次的进度开始重放尚未处理的事件。
+//
+// 事件参数通过 Codec 编解码，解码时按订阅函数的入参类型还原；
+// context.Context 参数不会被持久化，重放时替换为 context.Background()。
+// 事件总是写入日志，DeadLetterHandler 对持久化总线无效。
+type DurableEventBus struct {
+	wal         *wal
+	offsets     *offsetStore
+	codec       Codec
+	onError     ErrorHandler
+	middlewares []Middleware
+
+	lock      sync.Mutex
+	cond      *sync.Cond // 消费进度变化
+	notify    chan struct{}
+	consumers map[*consumer]struct{}
+	names     map[string]*consumer
+	closed    bool
+
+	// shutdown 在订阅者全部退出、日志关闭后被关闭，closeErr 是关闭日志的结果
+	shutdown chan struct{}
+	closeErr error
+}
+
+// NewDurableEventBus 打开 dir 下的日志，创建持久化事件总线
+func NewDurableEventBus(dir string, options ...*Option) (*DurableEventBus, error) {
+	opts := defaultBusOptions()
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	w, err := openWAL(dir, opts.segmentSize, opts.syncWrites)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := openOffsetStore(dir)
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+
+	d := &DurableEventBus{
+		wal:         w,
+		offsets:     offsets,
+		codec:       opts.codec,
+		onError:     opts.onError,
+		middlewares: opts.middlewares,
+		notify:      make(chan struct{}),
+		consumers:   map[*consumer]struct{}{},
+		names:       map[string]*consumer{},
+	}
+	d.cond = sync.NewCond(&d.lock)
+	return d, nil
+}
+
+// Subscribe 订阅主题
+//
+// 通过 WithDurableName 指定名称时，从该名称上次提交的进度开始消费，第一次订阅时从日志最早的事件开始；
+// 不指定名称时只消费订阅之后发布的事件，也不记录进度。
+func (d *DurableEventBus) Subscribe(topic string, handler interface{}, options ...*SubscribeOption) (Subscription, error) {
+	opts := &subscribeOption{}
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	sub, err := newSubscriber(topic, handler, false, opts, d.middlewares)
+	if err != nil {
+		return nil, err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.closed {
+		return nil, ErrBusClosed
+	}
+
+	start := d.wal.committed()
+	if opts.durableName != "" {
+		if _, ok := d.names[opts.durableName]; ok {
+			return nil, fmt.Errorf("eventbus: durable subscriber %q already exists", opts.durableName)
+		}
+		offset, ok := d.offsets.get(opts.durableName)
+		if !ok {
+			offset = 0
+		}
+		start = offset
+	}
+
+	c := &consumer{
+		bus:    d,
+		sub:    sub,
+		name:   opts.durableName,
+		reader: newWALReader(d.wal, start),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	d.consumers[c] = struct{}{}
+	if c.name != "" {
+		d.names[c.name] = c
+	}
+	go c.run()
+	return c, nil
+}
+
+func (d *DurableEventBus) removeConsumer(c *consumer) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.consumers, c)
+	if d.names[c.name] == c {
+		delete(d.names, c.name)
+	}
+	d.cond.Broadcast()
+}
+
+// Publish 将事件追加到日志，写入成功后返回
+func (d *DurableEventBus) Publish(topic string, args ...interface{}) error {
+	encoded, err := encodeArgs(d.codec, args)
+	if err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.closed {
+		return ErrBusClosed
+	}
+	if _, err := d.wal.append(topic, encoded); err != nil {
+		return err
+	}
+
+	// 唤醒所有等待新事件的消费者
+	close(d.notify)
+	d.notify = make(chan struct{})
+	return nil
+}
+
+// changed 返回一个在下一次 Publish 时关闭的 channel
+func (d *DurableEventBus) changed() <-chan struct{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.notify
+}
+
+// progress 通知消费进度发生变化
+func (d *DurableEventBus) progress() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.cond.Broadcast()
+}
+
+// caughtUp 所有消费者是否都已处理完日志中的事件，调用方需持有锁
+func (d *DurableEventBus) caughtUp() bool {
+	committed := d.wal.committed()
+	for c := range d.consumers {
+		if c.reader.position() < committed {
+			return false
+		}
+	}
+	return true
+}
+
+// Drain 等待所有订阅者处理完目前日志中的事件，ctx 结束时返回 ctx.Err()
+func (d *DurableEventBus) Drain(ctx context.Context) error {
+	// ctx 结束时唤醒等待者，避免在 Cond 上永远阻塞
+	stop := context.AfterFunc(ctx, d.progress)
+	defer stop()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for !d.caughtUp() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		d.cond.Wait()
+	}
+	return nil
+}
+
+// Compact 删除所有持久化订阅者都已处理完的日志分段，返回删除的分段数
+//
+// 曾经订阅过的持久化订阅者即使当前没有订阅，它的进度也会阻止对应分段被删除。
+// 还没有任何持久化订阅者提交过进度时不删除任何分段，
+// 因为之后第一次订阅的持久化订阅者会从日志最早的事件开始消费。
+func (d *DurableEventBus) Compact() (int, error) {
+	// 持有锁直到删除完成，期间不会有新的订阅者从待删除的分段开始消费
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	min, ok := d.offsets.min()
+	if !ok {
+		return 0, nil
+	}
+	for c := range d.consumers {
+		if offset := c.reader.position(); offset < min {
+			min = offset
+		}
+	}
+	return d.wal.compact(min)
+}
+
+// Close 关闭事件总线：之后的 Publish 都会返回 ErrBusClosed，
+// 等待各个订阅者处理完当前事件后关闭日志，尚未处理的事件会在下次启动后重放。
+// ctx 结束时不再等待，返回 ctx.Err()，日志会在订阅者全部退出后再关闭，
+// 再次调用 Close 可以继续等待关闭完成
+func (d *DurableEventBus) Close(ctx context.Context) error {
+	d.lock.Lock()
+	if !d.closed {
+		d.closed = true
+		consumers := make([]*consumer, 0, len(d.consumers))
+		for c := range d.consumers {
+			consumers = append(consumers, c)
+		}
+		d.shutdown = make(chan struct{})
+		go d.closeWAL(consumers)
+	}
+	shutdown := d.shutdown
+	d.lock.Unlock()
+
+	select {
+	case <-shutdown:
+		return d.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeWAL 停止所有订阅者，等它们的 goroutine 退出后才关闭日志，
+// 避免关闭时还有订阅者在读日志
+func (d *DurableEventBus) closeWAL(consumers []*consumer) {
+	for _, c := range consumers {
+		c.Unsubscribe()
+	}
+	for _, c := range consumers {
+		<-c.done
+	}
+	d.closeErr = d.wal.close()
+	close(d.shutdown)
+}
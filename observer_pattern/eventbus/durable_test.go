@@ -0,0 +1,308 @@
+package eventbus
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+type walEvent struct {
+	ID    int
+	Items []string
+}
+
+func drain(t *testing.T, bus *DurableEventBus) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bus.Drain(ctx); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+}
+
+func TestDurableEventBus_ReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	bus, err := NewDurableEventBus(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	var lock sync.Mutex
+	var got []int
+	handler := func(ctx context.Context, e walEvent) error {
+		lock.Lock()
+		got = append(got, e.ID)
+		lock.Unlock()
+		return nil
+	}
+	bus.Subscribe("order:*", handler, WithDurableName("billing"))
+	for i := 0; i < 3; i++ {
+		if err := bus.Publish("order:paid", context.Background(), walEvent{ID: i, Items: []string{"a"}}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	drain(t, bus)
+	bus.Close(context.Background())
+
+	// 模拟订阅者还没处理就退出了：重新打开后先发布，再订阅
+	bus, err = NewDurableEventBus(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer bus.Close(context.Background())
+	for i := 3; i < 5; i++ {
+		bus.Publish("order:paid", context.Background(), walEvent{ID: i})
+	}
+	bus.Publish("user:created", context.Background(), walEvent{ID: 99})
+	bus.Subscribe("order:*", handler, WithDurableName("billing"))
+	drain(t, bus)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(got) != 5 {
+		t.Fatalf("expected 5 events, got %v", got)
+	}
+	for i, id := range got {
+		if id != i {
+			t.Fatalf("expected events in order, got %v", got)
+		}
+	}
+}
+
+func TestDurableEventBus_DuplicateDurableName(t *testing.T) {
+	bus, err := NewDurableEventBus(t.TempDir())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer bus.Close(context.Background())
+
+	if _, err := bus.Subscribe("a", func() {}, WithDurableName("x")); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, err := bus.Subscribe("b", func() {}, WithDurableName("x")); err == nil {
+		t.Fatal("expected error for duplicate durable name")
+	}
+}
+
+func TestDurableEventBus_TruncatesTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	bus, err := NewDurableEventBus(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	bus.Publish("topic:1", 1)
+	bus.Close(context.Background())
+
+	// 模拟写入一半时崩溃
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	f, _ := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0o644)
+	f.Write([]byte{0, 0, 0, 10, 1, 2})
+	f.Close()
+
+	bus, err = NewDurableEventBus(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer bus.Close(context.Background())
+	bus.Publish("topic:1", 2)
+
+	var got []int
+	bus.Subscribe("topic:1", func(n int) { got = append(got, n) }, WithDurableName("reader"))
+	drain(t, bus)
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("unexpected events: %v", got)
+	}
+}
+
+func TestDurableEventBus_Compact(t *testing.T) {
+	dir := t.TempDir()
+	bus, err := NewDurableEventBus(dir, WithSegmentSize(128))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer bus.Close(context.Background())
+
+	block := make(chan struct{})
+	fast, _ := bus.Subscribe("topic:1", func(n int) {}, WithDurableName("fast"))
+	bus.Subscribe("topic:1", func(n int) { <-block }, WithDurableName("slow"))
+	for i := 0; i < 20; i++ {
+		bus.Publish("topic:1", i)
+	}
+
+	// slow 还停在第一个事件，不能删除任何分段
+	if removed, err := bus.Compact(); err != nil || removed != 0 {
+		t.Fatalf("expected nothing compacted, got %d, %v", removed, err)
+	}
+
+	close(block)
+	drain(t, bus)
+	fast.Unsubscribe()
+
+	before, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	removed, err := bus.Compact()
+	if err != nil || removed == 0 {
+		t.Fatalf("expected segments compacted, got %d, %v", removed, err)
+	}
+	after, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(after) != len(before)-removed || len(after) == 0 {
+		t.Fatalf("unexpected segments: before %d, after %d, removed %d", len(before), len(after), removed)
+	}
+
+	// 压缩后新的订阅者从剩余的日志开始消费
+	var got []int
+	bus.Subscribe("topic:1", func(n int) { got = append(got, n) }, WithDurableName("late"))
+	bus.Publish("topic:1", 20)
+	drain(t, bus)
+	if len(got) == 0 || got[len(got)-1] != 20 {
+		t.Fatalf("unexpected events after compaction: %v", got)
+	}
+}
+
+func TestDurableEventBus_CompactWithoutDurableOffsets(t *testing.T) {
+	dir := t.TempDir()
+	bus, err := NewDurableEventBus(dir, WithSegmentSize(128))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer bus.Close(context.Background())
+
+	// 只有非持久化订阅者时，事件还没有被任何持久化订阅者处理过
+	bus.Subscribe("topic:1", func(n int) {})
+	for i := 0; i < 20; i++ {
+		bus.Publish("topic:1", i)
+	}
+	drain(t, bus)
+
+	if removed, err := bus.Compact(); err != nil || removed != 0 {
+		t.Fatalf("expected nothing compacted, got %d, %v", removed, err)
+	}
+
+	var got []int
+	bus.Subscribe("topic:1", func(n int) { got = append(got, n) }, WithDurableName("billing"))
+	drain(t, bus)
+	if len(got) != 20 {
+		t.Fatalf("expected billing to replay 20 events, got %d", len(got))
+	}
+}
+
+func TestDurableEventBus_CompactWhileConsuming(t *testing.T) {
+	dir := t.TempDir()
+	bus, err := NewDurableEventBus(dir, WithSegmentSize(128))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer bus.Close(context.Background())
+
+	var lock sync.Mutex
+	var got []int
+	bus.Subscribe("topic:1", func(n int) {
+		lock.Lock()
+		got = append(got, n)
+		lock.Unlock()
+	}, WithDurableName("billing"))
+
+	// 一边提交进度一边压缩，不能删掉还没处理的事件
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			bus.Publish("topic:1", i)
+		}
+	}()
+	for compacting := true; compacting; {
+		select {
+		case <-done:
+			compacting = false
+		default:
+		}
+		if _, err := bus.Compact(); err != nil {
+			t.Fatalf("compact: %v", err)
+		}
+	}
+	drain(t, bus)
+
+	lock.Lock()
+	defer lock.Unlock()
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("expected events in order, got %d at %d", n, i)
+		}
+	}
+	if len(got) != 200 {
+		t.Fatalf("expected 200 events, got %d", len(got))
+	}
+}
+
+func TestDurableEventBus_CloseDeadline(t *testing.T) {
+	dir := t.TempDir()
+	bus, err := NewDurableEventBus(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	gate := make(chan struct{})
+	bus.Subscribe("topic:1", func(n int) { <-gate }, WithDurableName("slow"))
+	bus.Publish("topic:1", 1)
+	bus.Publish("topic:1", 2)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if err := bus.Drain(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("expected DeadlineExceeded from drain, got %v", err)
+		}
+		cancel()
+	}
+	assertNoGoroutineLeak(t, before)
+
+	// 超时后订阅者还在处理事件，日志保持打开
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded from close, got %v", err)
+	}
+	walOpen := func() bool {
+		bus.wal.mu.Lock()
+		defer bus.wal.mu.Unlock()
+		return bus.wal.active != nil
+	}
+	if !walOpen() {
+		t.Fatalf("expected wal open while a consumer is running")
+	}
+
+	// 订阅者退出后日志关闭，再次 Close 等到关闭完成
+	close(gate)
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if walOpen() {
+		t.Fatalf("expected wal closed once the consumer exited")
+	}
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("close again: %v", err)
+	}
+
+	// 第一条事件处理完后订阅者退出，第二条在重新打开后重放
+	reopened, err := NewDurableEventBus(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close(context.Background())
+	var lock sync.Mutex
+	var got []int
+	reopened.Subscribe("topic:1", func(n int) {
+		lock.Lock()
+		got = append(got, n)
+		lock.Unlock()
+	}, WithDurableName("slow"))
+	drain(t, reopened)
+	lock.Lock()
+	defer lock.Unlock()
+	if len(got) != 1 || got[0] != 2 {
+		t.Fatalf("expected event 2 replayed, got %v", got)
+	}
+}
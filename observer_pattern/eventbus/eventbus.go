@@ -1,61 +1,292 @@
 package eventbus
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 )
 
 // Bus 消息总线接口
 type Bus interface {
-	Subscribe(topic string, handler interface{}) error
-	Publish(topic string, args ...interface{})
+	Subscribe(topic string, handler interface{}, options ...*SubscribeOption) (Subscription, error)
+	Publish(topic string, args ...interface{}) error
+	Close(ctx context.Context) error
+}
+
+// Mode 事件总线的分发模式
+type Mode string
+
+const (
+	// ModeAsync 异步分发，不保证顺序，对应 AsyncEventBus
+	ModeAsync Mode = "async"
+	// ModeOrdered 异步分发，同一主题（或同一分区键）的事件按发布顺序依次处理
+	ModeOrdered Mode = "ordered"
+	// ModeSync 在 Publish 中同步执行，对应 SyncEventBus
+	ModeSync Mode = "sync"
+)
+
+// NewBus 按分发模式创建事件总线，方便通过配置切换实现
+func NewBus(mode Mode, options ...*Option) (Bus, error) {
+	switch mode {
+	case ModeAsync, "":
+		return NewAsyncEventBus(options...), nil
+	case ModeOrdered:
+		return NewAsyncEventBus(append([]*Option{WithOrderedDelivery()}, options...)...), nil
+	case ModeSync:
+		return NewSyncEventBus(options...), nil
+	}
+	return nil, fmt.Errorf("eventbus: unknown mode %q", mode)
+}
+
+// Subscription 订阅句柄，用于取消订阅
+type Subscription interface {
+	// Topic 订阅的主题
+	Topic() string
+	// Unsubscribe 取消订阅，可重复调用
+	Unsubscribe()
+}
+
+// subscriber 一个订阅者
+type subscriber struct {
+	id          uint64 // 订阅顺序
+	reg         *registry
+	topic       string
+	fn          reflect.Value
+	once        bool
+	retry       RetryPolicy
+	middlewares []Middleware
+	fired       int32 // once 订阅是否已经触发
+	removed     int32 // 是否已经取消订阅
+}
+
+func (s *subscriber) Topic() string {
+	return s.topic
+}
+
+func (s *subscriber) Unsubscribe() {
+	if atomic.CompareAndSwapInt32(&s.removed, 0, 1) && s.reg != nil {
+		s.reg.remove(s)
+	}
+}
+
+// acquire 判断本次事件是否应该交给该订阅者处理
+func (s *subscriber) acquire() bool {
+	if atomic.LoadInt32(&s.removed) == 1 {
+		return false
+	}
+	if !s.once {
+		return true
+	}
+	if !atomic.CompareAndSwapInt32(&s.fired, 0, 1) {
+		return false
+	}
+	s.Unsubscribe()
+	return true
+}
+
+// registry 订阅关系，各个总线实现共用
+type registry struct {
+	lock        sync.Mutex
+	trie        *topicTrie
+	nextID      uint64
+	middlewares []Middleware      // 总线级别的中间件
+	onRemove    func(*subscriber) // 订阅者被移除后的回调，可以为 nil
+}
+
+func newRegistry(middlewares []Middleware) *registry {
+	return &registry{
+		trie:        newTopicTrie(),
+		middlewares: middlewares,
+	}
+}
+
+// newSubscriber 校验 handler 并创建订阅者，总线中间件在外层，订阅者中间件在内层
+func newSubscriber(topic string, handler interface{}, once bool, opts *subscribeOption, middlewares []Middleware) (*subscriber, error) {
+	v := reflect.ValueOf(handler)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("handler is not a function")
+	}
+
+	return &subscriber{
+		topic:       topic,
+		fn:          v,
+		once:        once,
+		retry:       opts.retry,
+		middlewares: append(append([]Middleware{}, middlewares...), opts.middlewares...),
+	}, nil
+}
+
+// subscribe 添加订阅者
+func (r *registry) subscribe(topic string, handler interface{}, once bool, options []*SubscribeOption) (*subscriber, error) {
+	opts := &subscribeOption{}
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	sub, err := newSubscriber(topic, handler, once, opts, r.middlewares)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.nextID++
+	sub.id = r.nextID
+	sub.reg = r
+	r.trie.add(sub)
+	return sub, nil
+}
+
+// remove 移除订阅者
+func (r *registry) remove(sub *subscriber) {
+	r.lock.Lock()
+	r.trie.remove(sub)
+	r.lock.Unlock()
+
+	if r.onRemove != nil {
+		r.onRemove(sub)
+	}
+}
+
+// match 找出所有匹配 topic 的订阅者，按订阅先后排序
+func (r *registry) match(topic string) []*subscriber {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.trie.match(topic)
 }
 
 // AsyncEventBus 异步事件总线
+//
+// 发布的事件先进入所属主题的有界队列，再由固定数量的 worker 取出执行，
+// 同一个事件的多个订阅者在同一个 worker 中按订阅顺序依次执行。
+// 开启 WithOrderedDelivery 后，同一主题（或同一分区键）的事件按发布顺序逐个处理。
 type AsyncEventBus struct {
-	handlers map[string][]reflect.Value
-	lock     sync.Mutex
+	handlers   *registry
+	dispatch   *dispatcher
+	onError    ErrorHandler
+	deadLetter DeadLetterHandler
 }
 
 // NewAsyncEventBus 创建一个新的异步事件总线
-func NewAsyncEventBus() *AsyncEventBus {
-	return &AsyncEventBus{
-		handlers: map[string][]reflect.Value{},
-		lock:     sync.Mutex{},
+func NewAsyncEventBus(options ...*Option) *AsyncEventBus {
+	opts := defaultBusOptions()
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	a := &AsyncEventBus{
+		handlers:   newRegistry(opts.middlewares),
+		onError:    opts.onError,
+		deadLetter: opts.deadLetter,
 	}
+	a.dispatch = newDispatcher(opts, a.run)
+	return a
 }
 
-func (a *AsyncEventBus) Subscribe(topic string, handler interface{}) error {
-	a.lock.Lock()
-	defer a.lock.Unlock()
+// Subscribe 订阅主题，返回的 Subscription 可用于取消订阅
+//
+// topic 可以使用通配符：* 匹配一个分段，# 匹配零个或多个分段，如 "order:*"、"game:#"。
+//
+// handler 最后一个返回值为 error 且不为 nil 时视为处理失败，
+// 失败和 panic 都会按重试策略重试，最终交给总线的 ErrorHandler。
+func (a *AsyncEventBus) Subscribe(topic string, handler interface{}, options ...*SubscribeOption) (Subscription, error) {
+	return a.handlers.subscribe(topic, handler, false, options)
+}
 
-	v := reflect.ValueOf(handler)
-	if v.Type().Kind() != reflect.Func {
-		return fmt.Errorf("handler is not a function")
+// SubscribeOnce 订阅主题，handler 只会被触发一次，触发后自动取消订阅
+func (a *AsyncEventBus) SubscribeOnce(topic string, handler interface{}, options ...*SubscribeOption) (Subscription, error) {
+	return a.handlers.subscribe(topic, handler, true, options)
+}
+
+// Publish 发布事件，事件进入主题队列后即返回；
+// 队列满时按主题的溢出策略阻塞、丢弃或返回 ErrQueueFull。
+// 没有任何订阅者匹配 topic 时，事件交给 DeadLetterHandler。
+func (a *AsyncEventBus) Publish(topic string, args ...interface{}) error {
+	if a.dispatch.isClosed() {
+		return ErrBusClosed
+	}
+
+	handlers := a.handlers.match(topic)
+	if len(handlers) == 0 {
+		if a.deadLetter != nil {
+			a.deadLetter(topic, args)
+		}
+		return nil
 	}
 
-	handler, ok := a.handlers[topic]
-	if !ok {
-		handler = []reflect.Value{}
+	return a.dispatch.enqueue(&task{
+		topic: topic,
+		args:  args,
+		subs:  handlers,
+	})
+}
+
+// WaitAsync 阻塞等待所有已发布的事件处理完毕，常用于测试
+func (a *AsyncEventBus) WaitAsync() {
+	_ = a.dispatch.wait(context.Background())
+}
+
+// Drain 等待所有已发布的事件处理完毕，ctx 结束时返回 ctx.Err()
+//
+// Drain 不会阻止新的发布，如果发布一直在进行，Drain 可能等到 ctx 结束才返回。
+func (a *AsyncEventBus) Drain(ctx context.Context) error {
+	return a.dispatch.wait(ctx)
+}
+
+// Close 关闭事件总线：之后的 Publish 都会返回 ErrBusClosed，
+// 并等待已经接受的事件处理完毕，ctx 结束时返回 ctx.Err()，剩余事件仍会在后台继续处理
+func (a *AsyncEventBus) Close(ctx context.Context) error {
+	a.dispatch.close()
+	return a.dispatch.wait(ctx)
+}
+
+// Stats 获取主题队列的运行状态，可用于观察消费者是否跟不上发布速度
+func (a *AsyncEventBus) Stats(topic string) TopicStats {
+	return a.dispatch.stats(topic)
+}
+
+// run 在 worker 中执行一个事件
+func (a *AsyncEventBus) run(t *task) {
+	for _, sub := range t.subs {
+		if err := deliver(sub, t.topic, t.args); err != nil {
+			a.onError(err)
+		}
 	}
-	handler = append(handler.([]reflect.Value), v)
-	a.handlers[topic] = handler.([]reflect.Value)
-	return nil
 }
 
-func (a *AsyncEventBus) Publish(topic string, args ...interface{}) {
-	handlers, ok := a.handlers[topic]
-	if !ok {
-		fmt.Printf("not found handler in topoc: %s\n", topic)
-		return
+// buildParams 按 handler 的入参类型校验并转换 args，避免 reflect.Value.Call 因参数不匹配而 panic
+func buildParams(fn reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	numIn := fn.NumIn()
+	if fn.IsVariadic() {
+		if len(args) < numIn-1 {
+			return nil, fmt.Errorf("handler expects at least %d args, got %d", numIn-1, len(args))
+		}
+	} else if len(args) != numIn {
+		return nil, fmt.Errorf("handler expects %d args, got %d", numIn, len(args))
 	}
 
 	params := make([]reflect.Value, len(args))
 	for i, arg := range args {
-		params[i] = reflect.ValueOf(arg)
-	}
-	for _, handler := range handlers {
-		go handler.Call(params)
+		in := paramType(fn, i)
+
+		if arg == nil {
+			switch in.Kind() {
+			case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+				params[i] = reflect.Zero(in)
+				continue
+			}
+			return nil, fmt.Errorf("arg %d is nil, cannot be used as %s", i, in)
+		}
+
+		v := reflect.ValueOf(arg)
+		if !v.Type().AssignableTo(in) {
+			return nil, fmt.Errorf("arg %d has type %s, handler expects %s", i, v.Type(), in)
+		}
+		params[i] = v
 	}
+	return params, nil
 }
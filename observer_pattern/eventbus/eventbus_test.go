@@ -1,7 +1,14 @@
 package eventbus
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -21,5 +28,535 @@ func TestAsyncEventBus_Publish(t *testing.T) {
 	bus.Subscribe("topic:2", sub2)
 	bus.Publish("topic:1", "test1", "test2")
 	bus.Publish("topic:2", "testA", "testB")
-	time.Sleep(1 * time.Second)
+	bus.WaitAsync()
+}
+
+type orderPaid struct {
+	OrderID string
+	Amount  int
+}
+
+func TestTopic_PublishSubscribe(t *testing.T) {
+	bus := NewAsyncEventBus()
+	topic := NewTopic[orderPaid](bus, "order:paid")
+
+	got := make(chan orderPaid, 1)
+	_, err := topic.Subscribe(func(ctx context.Context, event orderPaid) error {
+		got <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	topic.Publish(context.Background(), orderPaid{OrderID: "o-1", Amount: 100})
+
+	select {
+	case event := <-got:
+		if event.OrderID != "o-1" || event.Amount != 100 {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+}
+
+func TestAsyncEventBus_PublishMismatchedArgs(t *testing.T) {
+	bus := NewAsyncEventBus()
+	called := make(chan struct{}, 1)
+	bus.Subscribe("topic:1", func(n int) { called <- struct{}{} })
+
+	// 参数类型与个数不匹配时不应 panic，也不应调用 handler
+	bus.Publish("topic:1", "not an int")
+	bus.Publish("topic:1", 1, 2)
+	bus.WaitAsync()
+
+	select {
+	case <-called:
+		t.Fatal("handler should not be called with mismatched args")
+	default:
+	}
+}
+
+func TestAsyncEventBus_Unsubscribe(t *testing.T) {
+	bus := NewAsyncEventBus()
+	called := make(chan struct{}, 1)
+	sub, err := bus.Subscribe("topic:1", func() { called <- struct{}{} })
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	bus.Publish("topic:1")
+	bus.WaitAsync()
+
+	select {
+	case <-called:
+		t.Fatal("handler called after unsubscribe")
+	default:
+	}
+}
+
+func TestAsyncEventBus_SubscribeOnce(t *testing.T) {
+	bus := NewAsyncEventBus()
+	var count int32
+	bus.SubscribeOnce("topic:1", func() {
+		atomic.AddInt32(&count, 1)
+	})
+
+	for i := 0; i < 3; i++ {
+		bus.Publish("topic:1")
+	}
+
+	bus.WaitAsync()
+	if n := atomic.LoadInt32(&count); n != 1 {
+		t.Fatalf("once handler called %d times", n)
+	}
+}
+
+func TestAsyncEventBus_UnsubscribeWhilePublishing(t *testing.T) {
+	bus := NewAsyncEventBus()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub, _ := bus.Subscribe("topic:1", func(int) {})
+			bus.Publish("topic:1", 1)
+			sub.Unsubscribe()
+		}()
+	}
+	wg.Wait()
+}
+
+// waitUntil 轮询等待条件成立
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newBlockedBus 创建一个只有一个 worker 的总线，并让 worker 阻塞在第一个事件上
+func newBlockedBus(t *testing.T, policy OverflowPolicy) (*AsyncEventBus, chan struct{}, chan int) {
+	bus := NewAsyncEventBus(
+		WithWorkers(1),
+		WithTopicConfig("topic:1", TopicConfig{QueueSize: 2, Overflow: policy}),
+	)
+	gate := make(chan struct{})
+	got := make(chan int, 10)
+	bus.Subscribe("topic:1", func(n int) {
+		<-gate
+		got <- n
+	})
+
+	if err := bus.Publish("topic:1", 0); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	waitUntil(t, func() bool { return bus.Stats("topic:1").Depth == 0 })
+	bus.Publish("topic:1", 1)
+	bus.Publish("topic:1", 2)
+	return bus, gate, got
+}
+
+func TestAsyncEventBus_OverflowReturnError(t *testing.T) {
+	bus, gate, _ := newBlockedBus(t, ReturnError)
+	defer close(gate)
+
+	if err := bus.Publish("topic:1", 3); err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if stats := bus.Stats("topic:1"); stats.Depth != 2 || stats.Capacity != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestAsyncEventBus_OverflowDrop(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   []int
+	}{
+		{DropNewest, []int{0, 1, 2}},
+		{DropOldest, []int{0, 2, 3}},
+	}
+	for _, tt := range tests {
+		bus, gate, got := newBlockedBus(t, tt.policy)
+		if err := bus.Publish("topic:1", 3); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		if stats := bus.Stats("topic:1"); stats.Dropped != 1 {
+			t.Fatalf("policy %d: expected 1 dropped, got %+v", tt.policy, stats)
+		}
+		close(gate)
+		for _, want := range tt.want {
+			if n := <-got; n != want {
+				t.Fatalf("policy %d: expected %d, got %d", tt.policy, want, n)
+			}
+		}
+	}
+}
+
+func TestAsyncEventBus_OverflowBlock(t *testing.T) {
+	bus, gate, got := newBlockedBus(t, Block)
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish("topic:1", 3)
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("publish should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(gate)
+	<-published
+	for want := 0; want < 4; want++ {
+		if n := <-got; n != want {
+			t.Fatalf("expected %d, got %d", want, n)
+		}
+	}
+}
+
+func TestAsyncEventBus_Close(t *testing.T) {
+	bus := NewAsyncEventBus(WithWorkers(2))
+	var count int32
+	bus.Subscribe("topic:1", func() {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&count, 1)
+	})
+	for i := 0; i < 5; i++ {
+		bus.Publish("topic:1")
+	}
+
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if n := atomic.LoadInt32(&count); n != 5 {
+		t.Fatalf("expected 5 handled events after close, got %d", n)
+	}
+	if err := bus.Publish("topic:1"); err != ErrBusClosed {
+		t.Fatalf("expected ErrBusClosed, got %v", err)
+	}
+}
+
+func TestAsyncEventBus_CloseDeadline(t *testing.T) {
+	bus := NewAsyncEventBus(WithWorkers(1))
+	gate := make(chan struct{})
+	defer close(gate)
+	bus.Subscribe("topic:1", func() { <-gate })
+	bus.Publish("topic:1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestAsyncEventBus_DrainDeadlineNoLeak(t *testing.T) {
+	bus := NewAsyncEventBus(WithWorkers(1))
+	gate := make(chan struct{})
+	defer close(gate)
+	bus.Subscribe("topic:1", func() { <-gate })
+	bus.Publish("topic:1")
+	time.Sleep(10 * time.Millisecond)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if err := bus.Drain(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("expected DeadlineExceeded, got %v", err)
+		}
+		cancel()
+	}
+	// 超时返回后不应残留等待的 goroutine
+	assertNoGoroutineLeak(t, before)
+}
+
+func TestAsyncEventBus_CloseWakesBlockedPublisher(t *testing.T) {
+	bus, gate, _ := newBlockedBus(t, Block)
+	defer close(gate)
+
+	errs := make(chan error, 1)
+	go func() { errs <- bus.Publish("topic:1", 3) }()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	bus.Close(ctx)
+	if err := <-errs; err != ErrBusClosed {
+		t.Fatalf("expected ErrBusClosed, got %v", err)
+	}
+}
+
+func TestAsyncEventBus_HandlerPanic(t *testing.T) {
+	errs := make(chan *HandlerError, 1)
+	bus := NewAsyncEventBus(WithErrorHandler(func(err *HandlerError) { errs <- err }))
+	bus.Subscribe("topic:1", func(msg string) { panic("boom: " + msg) })
+
+	bus.Publish("topic:1", "hello")
+	bus.WaitAsync()
+
+	err := <-errs
+	if err.Topic != "topic:1" || err.Args[0] != "hello" {
+		t.Fatalf("unexpected error context: %+v", err)
+	}
+	if err.Panic != "boom: hello" || len(err.Stack) == 0 {
+		t.Fatalf("expected panic value and stack, got %+v", err)
+	}
+}
+
+func TestAsyncEventBus_HandlerErrorRetry(t *testing.T) {
+	errs := make(chan *HandlerError, 1)
+	bus := NewAsyncEventBus(WithErrorHandler(func(err *HandlerError) { errs <- err }))
+
+	failure := fmt.Errorf("temporary failure")
+	var calls int32
+	bus.Subscribe("topic:1", func() error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return failure
+		}
+		return nil
+	}, WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}))
+	bus.Subscribe("topic:2", func() error {
+		return failure
+	}, WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	bus.Publish("topic:1")
+	bus.WaitAsync()
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("expected 3 calls, got %d", n)
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	bus.Publish("topic:2")
+	bus.WaitAsync()
+	err := <-errs
+	if err.Attempts != 3 || !errors.Is(err, failure) || err.Panic != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w*time.Millisecond {
+			t.Fatalf("attempt %d: expected %v, got %v", i+1, w*time.Millisecond, got)
+		}
+	}
+}
+
+func TestTopicTrie_Match(t *testing.T) {
+	patterns := []string{"order:paid", "order:*", "order:#", "#", "*:paid", "game:#:end", "game:*"}
+	trie := newTopicTrie()
+	subs := map[string]*subscriber{}
+	for i, p := range patterns {
+		subs[p] = &subscriber{id: uint64(i + 1), topic: p}
+		trie.add(subs[p])
+	}
+
+	tests := []struct {
+		topic string
+		want  []string
+	}{
+		{"order:paid", []string{"order:paid", "order:*", "order:#", "#", "*:paid"}},
+		{"order:paid:1", []string{"order:#", "#"}},
+		{"order", []string{"order:#", "#"}},
+		{"game:end", []string{"#", "game:#:end", "game:*"}},
+		{"game:round:1:end", []string{"#", "game:#:end"}},
+		{"user:created", []string{"#"}},
+	}
+	for _, tt := range tests {
+		got := trie.match(tt.topic)
+		var names []string
+		for _, sub := range got {
+			names = append(names, sub.topic)
+		}
+		want := append([]string{}, tt.want...)
+		sort.Slice(want, func(i, j int) bool { return subs[want[i]].id < subs[want[j]].id })
+		if strings.Join(names, ",") != strings.Join(want, ",") {
+			t.Errorf("match %q: expected %v, got %v", tt.topic, want, names)
+		}
+	}
+
+	for _, p := range patterns {
+		trie.remove(subs[p])
+	}
+	if len(trie.root.children) != 0 {
+		t.Fatalf("expected empty trie after removing all subscribers")
+	}
+}
+
+func TestAsyncEventBus_WildcardAndDeadLetter(t *testing.T) {
+	dead := make(chan string, 1)
+	bus := NewAsyncEventBus(WithDeadLetter(func(topic string, args []interface{}) { dead <- topic }))
+
+	var count int32
+	bus.Subscribe("order:*", func(id string) { atomic.AddInt32(&count, 1) })
+	bus.Subscribe("order:#", func(id string) { atomic.AddInt32(&count, 1) })
+
+	bus.Publish("order:paid", "o-1")
+	bus.Publish("order:paid:refund", "o-1")
+	bus.Publish("user:created", "u-1")
+	bus.WaitAsync()
+
+	if n := atomic.LoadInt32(&count); n != 3 {
+		t.Fatalf("expected 3 deliveries, got %d", n)
+	}
+	if topic := <-dead; topic != "user:created" {
+		t.Fatalf("unexpected dead letter topic: %s", topic)
+	}
+}
+
+func TestSyncEventBus_Publish(t *testing.T) {
+	bus := NewSyncEventBus()
+	var order []string
+	failure := errors.New("failure")
+	bus.Subscribe("order:paid", func(id string) { order = append(order, "first:"+id) })
+	bus.Subscribe("order:*", func(id string) error {
+		order = append(order, "second:"+id)
+		return failure
+	})
+	bus.Subscribe("order:#", func(id string) { panic("third") })
+
+	err := bus.Publish("order:paid", "o-1")
+	if strings.Join(order, ",") != "first:o-1,second:o-1" {
+		t.Fatalf("unexpected call order: %v", order)
+	}
+
+	var perr *PublishError
+	if !errors.As(err, &perr) || len(perr.Errors) != 2 {
+		t.Fatalf("expected PublishError with 2 errors, got %v", err)
+	}
+	if !errors.Is(err, failure) || perr.Errors[1].Panic != "third" {
+		t.Fatalf("unexpected errors: %v", perr.Errors)
+	}
+
+	bus.Close(context.Background())
+	if err := bus.Publish("order:paid", "o-2"); err != ErrBusClosed {
+		t.Fatalf("expected ErrBusClosed, got %v", err)
+	}
+}
+
+func TestSyncEventBus_CloseDeadline(t *testing.T) {
+	bus := NewSyncEventBus()
+	gate := make(chan struct{})
+	bus.Subscribe("topic:1", func() { <-gate })
+	go bus.Publish("topic:1")
+	time.Sleep(10 * time.Millisecond)
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	assertNoGoroutineLeak(t, before)
+
+	close(gate)
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestAsyncEventBus_OrderedDelivery(t *testing.T) {
+	bus, err := NewBus(ModeOrdered, WithWorkers(8))
+	if err != nil {
+		t.Fatalf("new bus: %v", err)
+	}
+
+	var lock sync.Mutex
+	got := map[string][]int{}
+	handler := func(topic string, n int) {
+		lock.Lock()
+		got[topic] = append(got[topic], n)
+		lock.Unlock()
+	}
+	bus.Subscribe("topic:1", handler)
+	bus.Subscribe("topic:2", handler)
+
+	for i := 0; i < 200; i++ {
+		bus.Publish("topic:1", "topic:1", i)
+		bus.Publish("topic:2", "topic:2", i)
+	}
+	bus.Close(context.Background())
+
+	for _, topic := range []string{"topic:1", "topic:2"} {
+		for i, n := range got[topic] {
+			if n != i {
+				t.Fatalf("%s out of order at %d: got %d", topic, i, n)
+			}
+		}
+		if len(got[topic]) != 200 {
+			t.Fatalf("%s expected 200 events, got %d", topic, len(got[topic]))
+		}
+	}
+}
+
+func TestAsyncEventBus_PartitionKey(t *testing.T) {
+	bus := NewAsyncEventBus(
+		WithWorkers(8),
+		WithPartitionKey(func(topic string, args []interface{}) string { return args[0].(string) }),
+	)
+
+	var lock sync.Mutex
+	got := map[string][]int{}
+	bus.Subscribe("player:move", func(player string, n int) {
+		lock.Lock()
+		got[player] = append(got[player], n)
+		lock.Unlock()
+	})
+
+	players := []string{"p1", "p2", "p3"}
+	for i := 0; i < 100; i++ {
+		for _, p := range players {
+			bus.Publish("player:move", p, i)
+		}
+	}
+	bus.WaitAsync()
+
+	for _, p := range players {
+		if len(got[p]) != 100 {
+			t.Fatalf("%s expected 100 events, got %d", p, len(got[p]))
+		}
+		for i, n := range got[p] {
+			if n != i {
+				t.Fatalf("%s out of order at %d: got %d", p, i, n)
+			}
+		}
+	}
+	if stats := bus.Stats("player:move"); stats.Depth != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestNewBus_UnknownMode(t *testing.T) {
+	if _, err := NewBus("fifo"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+// assertNoGoroutineLeak 等待 goroutine 数量回落到 before 以下
+func assertNoGoroutineLeak(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine leak: %d before, %d after", before, runtime.NumGoroutine())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
 }
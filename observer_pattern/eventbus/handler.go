@@ -0,0 +1,194 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"runtime/debug"
+	"time"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// HandlerError 订阅者处理事件失败的详情
+type HandlerError struct {
+	Topic string
+	Args  []interface{}
+	// Err handler 返回的错误、panic 转换成的错误或参数不匹配的错误
+	Err error
+	// Panic handler panic 时的原始值，没有 panic 时为 nil
+	Panic interface{}
+	// Stack handler panic 时的调用栈，没有 panic 时为 nil
+	Stack []byte
+	// Attempts 一共尝试执行的次数
+	Attempts int
+}
+
+func (e *HandlerError) Error() string {
+	if e.Attempts == 0 {
+		return fmt.Sprintf("eventbus: handler in topic %s not called: %v", e.Topic, e.Err)
+	}
+	return fmt.Sprintf("eventbus: handler in topic %s failed after %d attempt(s): %v", e.Topic, e.Attempts, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler 订阅者失败时的回调，在执行事件的 worker 中同步调用
+type ErrorHandler func(err *HandlerError)
+
+// defaultErrorHandler 默认只打印日志
+func defaultErrorHandler(err *HandlerError) {
+	if err.Panic != nil {
+		log.Printf("%v\n%s", err, err.Stack)
+		return
+	}
+	log.Print(err)
+}
+
+// RetryPolicy 订阅者失败后的重试策略，两次重试的间隔按 Multiplier 指数增长
+type RetryPolicy struct {
+	// MaxAttempts 最多执行的次数（包含第一次），不大于 1 时不重试
+	MaxAttempts int
+	// InitialBackoff 第一次重试前的等待时间
+	InitialBackoff time.Duration
+	// MaxBackoff 重试间隔的上限，为 0 时不限制
+	MaxBackoff time.Duration
+	// Multiplier 重试间隔的增长倍数，小于 1 时按 2 处理
+	Multiplier float64
+}
+
+// backoff 第 attempt 次失败后需要等待的时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= multiplier
+		if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(d)
+}
+
+// panicError handler 中发生的 panic
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// invoke 调用 handler，把 panic 和最后一个 error 返回值统一转换为 error
+func invoke(fn reflect.Value, params []reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+
+	out := fn.Call(params)
+	if len(out) == 0 {
+		return nil
+	}
+	last := out[len(out)-1]
+	if !last.Type().Implements(errorType) {
+		return nil
+	}
+	switch last.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if last.IsNil() {
+			return nil
+		}
+	}
+	return last.Interface().(error)
+}
+
+// argError 事件参数与 handler 不匹配，重试也不会成功
+type argError struct {
+	err error
+}
+
+func (e *argError) Error() string {
+	return e.err.Error()
+}
+
+func (e *argError) Unwrap() error {
+	return e.err
+}
+
+// deliver 将事件经过中间件链交给一个订阅者处理，按订阅者的重试策略重试，返回最终的失败
+func deliver(sub *subscriber, topic string, args []interface{}) *HandlerError {
+	acquired := false
+	final := func(ctx context.Context, event Event) error {
+		params, err := buildParams(sub.fn.Type(), event.Args)
+		if err != nil {
+			return &argError{err: err}
+		}
+		if !acquired {
+			if !sub.acquire() {
+				return nil
+			}
+			acquired = true
+		}
+		// 用中间件传下来的 ctx 替换事件中的 context.Context 参数
+		for i, arg := range event.Args {
+			if _, ok := arg.(context.Context); ok && reflect.TypeOf(ctx).AssignableTo(paramType(sub.fn.Type(), i)) {
+				params[i] = reflect.ValueOf(ctx)
+			}
+		}
+		return invoke(sub.fn, params)
+	}
+
+	handler := chain(final, sub.middlewares)
+	event := Event{Topic: topic, Args: args}
+	ctx := contextOf(args)
+
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		err = handler(ctx, event)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*argError); ok {
+			return &HandlerError{Topic: topic, Args: args, Err: err}
+		}
+		if attempts >= sub.retry.MaxAttempts {
+			break
+		}
+		time.Sleep(sub.retry.backoff(attempts))
+	}
+
+	herr := &HandlerError{
+		Topic:    topic,
+		Args:     args,
+		Err:      err,
+		Attempts: attempts,
+	}
+	var p *panicError
+	if errors.As(err, &p) {
+		herr.Panic = p.value
+		herr.Stack = p.stack
+	}
+	return herr
+}
+
+// contextOf 事件参数中的第一个 context.Context，没有时返回 context.Background()
+func contextOf(args []interface{}) context.Context {
+	for _, arg := range args {
+		if ctx, ok := arg.(context.Context); ok && ctx != nil {
+			return ctx
+		}
+	}
+	return context.Background()
+}
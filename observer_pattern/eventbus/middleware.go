@@ -0,0 +1,177 @@
+package eventbus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"expvar"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event 分发给订阅者的一个事件
+type Event struct {
+	Topic string
+	Args  []interface{}
+}
+
+// HandlerFunc 中间件链中的处理函数
+//
+// ctx 取自事件参数中的第一个 context.Context，没有时为 context.Background()；
+// 中间件传给 next 的 ctx 会替换订阅函数收到的 context.Context 参数。
+type HandlerFunc func(ctx context.Context, event Event) error
+
+// Middleware 包装 HandlerFunc，在订阅者处理事件前后加入额外的行为
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// chain 按顺序组合中间件，第一个中间件在最外层
+func chain(final HandlerFunc, middlewares []Middleware) HandlerFunc {
+	h := final
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Filter 只让满足 predicate 的事件通过，其余事件直接视为处理成功
+func Filter(predicate func(event Event) bool) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event Event) error {
+			if !predicate(event) {
+				return nil
+			}
+			return next(ctx, event)
+		}
+	}
+}
+
+// Logging 使用 slog 记录每次处理的主题、耗时和错误，logger 为 nil 时使用 slog.Default()
+func Logging(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event Event) error {
+			start := time.Now()
+			err := next(ctx, event)
+
+			attrs := []slog.Attr{
+				slog.String("topic", event.Topic),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if traceID, ok := TraceIDFromContext(ctx); ok {
+				attrs = append(attrs, slog.String("trace_id", traceID))
+			}
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+				logger.LogAttrs(ctx, slog.LevelError, "eventbus: handle event failed", attrs...)
+				return err
+			}
+			logger.LogAttrs(ctx, slog.LevelDebug, "eventbus: handle event", attrs...)
+			return nil
+		}
+	}
+}
+
+// Metrics 按主题统计处理次数、失败次数和耗时
+//
+// Metrics 实现了 expvar.Var，可以通过 expvar.Publish 暴露在 /debug/vars 中，格式如：
+//
+//	{"order:paid": {"count": 10, "errors": 1, "latency_ns_total": 123456, "latency_ns_max": 34567}}
+type Metrics struct {
+	expvar.Map
+	lock sync.Mutex
+}
+
+// topicMetrics 单个主题的统计
+type topicMetrics struct {
+	count        *expvar.Int
+	errors       *expvar.Int
+	latencyTotal *expvar.Int
+	latencyMax   *expvar.Int
+}
+
+// NewMetrics 创建统计
+func NewMetrics() *Metrics {
+	m := &Metrics{}
+	m.Init()
+	return m
+}
+
+// topic 获取主题的统计，不存在时创建
+func (m *Metrics) topic(topic string) *topicMetrics {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	vars, ok := m.Get(topic).(*expvar.Map)
+	if !ok {
+		vars = new(expvar.Map).Init()
+		for _, key := range []string{"count", "errors", "latency_ns_total", "latency_ns_max"} {
+			vars.Set(key, new(expvar.Int))
+		}
+		m.Set(topic, vars)
+	}
+	return &topicMetrics{
+		count:        vars.Get("count").(*expvar.Int),
+		errors:       vars.Get("errors").(*expvar.Int),
+		latencyTotal: vars.Get("latency_ns_total").(*expvar.Int),
+		latencyMax:   vars.Get("latency_ns_max").(*expvar.Int),
+	}
+}
+
+// Middleware 返回记录统计的中间件
+func (m *Metrics) Middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event Event) error {
+			start := time.Now()
+			err := next(ctx, event)
+			latency := int64(time.Since(start))
+
+			stats := m.topic(event.Topic)
+			stats.count.Add(1)
+			if err != nil {
+				stats.errors.Add(1)
+			}
+			stats.latencyTotal.Add(latency)
+			m.lock.Lock()
+			if latency > stats.latencyMax.Value() {
+				stats.latencyMax.Set(latency)
+			}
+			m.lock.Unlock()
+			return err
+		}
+	}
+}
+
+type traceIDKey struct{}
+
+// WithTraceID 在 ctx 中设置链路追踪 ID，随事件参数中的 ctx 传递给订阅者
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext 获取 ctx 中的链路追踪 ID
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	traceID, ok := ctx.Value(traceIDKey{}).(string)
+	return traceID, ok && traceID != ""
+}
+
+// Tracing 保证每次处理都带有链路追踪 ID，ctx 中没有时生成一个新的
+func Tracing() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event Event) error {
+			if _, ok := TraceIDFromContext(ctx); !ok {
+				ctx = WithTraceID(ctx, newTraceID())
+			}
+			return next(ctx, event)
+		}
+	}
+}
+
+// newTraceID 生成 16 字节的随机 ID
+func newTraceID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
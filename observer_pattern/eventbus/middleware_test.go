@@ -0,0 +1,100 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware_Chain(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, event Event) error {
+				calls = append(calls, name+":before")
+				err := next(ctx, event)
+				calls = append(calls, name+":after")
+				return err
+			}
+		}
+	}
+
+	bus := NewSyncEventBus(WithMiddleware(record("bus")))
+	bus.Subscribe("topic:1", func() { calls = append(calls, "handler") }, WithSubscriberMiddleware(record("sub")))
+	bus.Publish("topic:1")
+
+	want := "bus:before,sub:before,handler,sub:after,bus:after"
+	if got := strings.Join(calls, ","); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestMiddleware_Filter(t *testing.T) {
+	bus := NewSyncEventBus()
+	var got []int
+	bus.SubscribeOnce("topic:1", func(n int) { got = append(got, n) }, WithFilter(func(event Event) bool {
+		return event.Args[0].(int)%2 == 0
+	}))
+
+	for i := 1; i <= 4; i++ {
+		bus.Publish("topic:1", i)
+	}
+	if len(got) != 1 || got[0] != 2 {
+		t.Fatalf("expected only the first even event, got %v", got)
+	}
+}
+
+func TestMiddleware_Tracing(t *testing.T) {
+	bus := NewSyncEventBus(WithMiddleware(Tracing()))
+	var traceIDs []string
+	bus.Subscribe("topic:1", func(ctx context.Context) {
+		traceID, _ := TraceIDFromContext(ctx)
+		traceIDs = append(traceIDs, traceID)
+	})
+
+	bus.Publish("topic:1", WithTraceID(context.Background(), "trace-1"))
+	bus.Publish("topic:1", context.Background())
+
+	if traceIDs[0] != "trace-1" {
+		t.Fatalf("expected propagated trace id, got %q", traceIDs[0])
+	}
+	if len(traceIDs[1]) != 32 {
+		t.Fatalf("expected generated trace id, got %q", traceIDs[1])
+	}
+}
+
+func TestMiddleware_MetricsAndLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	metrics := NewMetrics()
+
+	bus := NewSyncEventBus(WithMiddleware(Tracing(), Logging(logger), metrics.Middleware()))
+	bus.Subscribe("topic:1", func(fail bool) error {
+		if fail {
+			return errors.New("failure")
+		}
+		return nil
+	})
+	bus.Publish("topic:1", false)
+	bus.Publish("topic:1", true)
+
+	var stats map[string]map[string]int64
+	if err := json.Unmarshal([]byte(metrics.String()), &stats); err != nil {
+		t.Fatalf("metrics is not valid json: %v", err)
+	}
+	if stats["topic:1"]["count"] != 2 || stats["topic:1"]["errors"] != 1 {
+		t.Fatalf("unexpected metrics: %v", stats)
+	}
+	if stats["topic:1"]["latency_ns_max"] > stats["topic:1"]["latency_ns_total"] {
+		t.Fatalf("unexpected latency metrics: %v", stats)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"level":"ERROR"`) || !strings.Contains(lines[1], `"trace_id"`) {
+		t.Fatalf("unexpected logs: %s", buf.String())
+	}
+}
@@ -0,0 +1,243 @@
+package eventbus
+
+import (
+	"runtime"
+	"time"
+)
+
+// busOption 事件总线的可选配置项
+type busOption struct {
+	workers    int
+	topic      TopicConfig
+	topics     map[string]TopicConfig
+	onError    ErrorHandler
+	deadLetter DeadLetterHandler
+	ordered    bool
+	partition  PartitionFunc
+
+	codec       Codec
+	segmentSize int64
+	syncWrites  bool
+
+	middlewares []Middleware
+
+	heartbeat heartbeatConfig
+	reconnect RetryPolicy
+}
+
+// Option 事件总线配置
+type Option struct {
+	apply func(*busOption)
+}
+
+// defaultBusOptions 默认配置
+func defaultBusOptions() *busOption {
+	return &busOption{
+		workers: runtime.NumCPU(),
+		topic: TopicConfig{
+			QueueSize: 1024,
+			Overflow:  Block,
+		},
+		topics:      map[string]TopicConfig{},
+		onError:     defaultErrorHandler,
+		codec:       JSONCodec,
+		segmentSize: 64 << 20,
+		heartbeat:   heartbeatConfig{interval: 5 * time.Second},
+		reconnect: RetryPolicy{
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     5 * time.Second,
+		},
+	}
+}
+
+// WithWorkers 设置处理事件的 worker 数量
+func WithWorkers(n int) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			if n > 0 {
+				option.workers = n
+			}
+		},
+	}
+}
+
+// WithQueueSize 设置每个主题默认的队列长度
+func WithQueueSize(n int) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			if n > 0 {
+				option.topic.QueueSize = n
+			}
+		},
+	}
+}
+
+// WithOverflowPolicy 设置每个主题默认的队列溢出策略
+func WithOverflowPolicy(policy OverflowPolicy) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			option.topic.Overflow = policy
+		},
+	}
+}
+
+// WithTopicConfig 单独设置某个主题的队列配置，QueueSize 不大于 0 时使用默认长度
+func WithTopicConfig(topic string, cfg TopicConfig) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			option.topics[topic] = cfg
+		},
+	}
+}
+
+// WithErrorHandler 设置订阅者失败（返回 error、panic、参数不匹配）时的回调
+func WithErrorHandler(handler ErrorHandler) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			if handler != nil {
+				option.onError = handler
+			}
+		},
+	}
+}
+
+// DeadLetterHandler 没有订阅者匹配时的回调，在 Publish 中同步调用
+type DeadLetterHandler func(topic string, args []interface{})
+
+// WithDeadLetter 设置没有订阅者匹配时的回调，默认直接丢弃
+func WithDeadLetter(handler DeadLetterHandler) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			option.deadLetter = handler
+		},
+	}
+}
+
+// PartitionFunc 计算事件的分区键，同一主题下分区键相同的事件按发布顺序处理
+type PartitionFunc func(topic string, args []interface{}) string
+
+// WithOrderedDelivery 开启有序分发，同一主题的事件按发布顺序逐个处理
+func WithOrderedDelivery() *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			option.ordered = true
+		},
+	}
+}
+
+// WithPartitionKey 开启按分区键有序分发，同一主题下分区键相同的事件按发布顺序逐个处理，
+// 不同分区键之间可以并行
+func WithPartitionKey(partition PartitionFunc) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			option.ordered = true
+			option.partition = partition
+		},
+	}
+}
+
+// WithCodec 设置事件参数的编解码方式，默认 JSONCodec
+func WithCodec(codec Codec) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			if codec != nil {
+				option.codec = codec
+			}
+		},
+	}
+}
+
+// WithSegmentSize 设置持久化总线单个日志分段的大小上限，默认 64MB
+func WithSegmentSize(size int64) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			if size > 0 {
+				option.segmentSize = size
+			}
+		},
+	}
+}
+
+// WithSyncWrites 持久化总线每次写入日志后都调用 fsync，更安全但更慢
+func WithSyncWrites() *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			option.syncWrites = true
+		},
+	}
+}
+
+// WithMiddleware 为总线上的所有订阅者添加中间件，先添加的在外层
+func WithMiddleware(middlewares ...Middleware) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			option.middlewares = append(option.middlewares, middlewares...)
+		},
+	}
+}
+
+// WithHeartbeat 设置 RemoteBus 的心跳间隔，超过 3 个间隔没有收到任何数据即认为连接断开，默认 5s；
+// Broker 需要设置相同的间隔
+func WithHeartbeat(interval time.Duration) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			if interval > 0 {
+				option.heartbeat.interval = interval
+			}
+		},
+	}
+}
+
+// WithReconnectBackoff 设置 RemoteBus 断线重连的退避时间，默认从 100ms 开始翻倍，最多 5s
+func WithReconnectBackoff(initial, max time.Duration) *Option {
+	return &Option{
+		apply: func(option *busOption) {
+			option.reconnect.InitialBackoff = initial
+			option.reconnect.MaxBackoff = max
+		},
+	}
+}
+
+// subscribeOption 订阅时的可选配置项
+type subscribeOption struct {
+	retry       RetryPolicy
+	durableName string
+	middlewares []Middleware
+}
+
+// SubscribeOption 订阅配置
+type SubscribeOption struct {
+	apply func(*subscribeOption)
+}
+
+// WithRetry 设置订阅者失败后的重试策略
+func WithRetry(policy RetryPolicy) *SubscribeOption {
+	return &SubscribeOption{
+		apply: func(option *subscribeOption) {
+			option.retry = policy
+		},
+	}
+}
+
+// WithDurableName 设置持久化订阅者的名称，持久化总线会按名称记录消费进度
+func WithDurableName(name string) *SubscribeOption {
+	return &SubscribeOption{
+		apply: func(option *subscribeOption) {
+			option.durableName = name
+		},
+	}
+}
+
+// WithSubscriberMiddleware 为单个订阅者添加中间件，位于总线中间件的内层
+func WithSubscriberMiddleware(middlewares ...Middleware) *SubscribeOption {
+	return &SubscribeOption{
+		apply: func(option *subscribeOption) {
+			option.middlewares = append(option.middlewares, middlewares...)
+		},
+	}
+}
+
+// WithFilter 订阅者只接收满足 predicate 的事件
+func WithFilter(predicate func(event Event) bool) *SubscribeOption {
+	return WithSubscriberMiddleware(Filter(predicate))
+}
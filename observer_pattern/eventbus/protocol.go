@@ -0,0 +1,134 @@
+package eventbus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// GobCodec 使用 encoding/gob 编解码，事件参数必须是具体类型
+var GobCodec Codec = gobCodec{}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// frameType 帧类型
+type frameType byte
+
+const (
+	frameSubscribe frameType = iota + 1
+	frameUnsubscribe
+	framePublish
+	framePing
+	framePong
+)
+
+const (
+	frameHeaderSize = 5 // 4 字节长度 + 1 字节类型
+	maxFrameSize    = 16 << 20
+)
+
+// remoteMessage 帧的内容，订阅类的帧只有 Topic
+type remoteMessage struct {
+	Topic string
+	Args  []encodedArg
+}
+
+// frameConn 在连接上收发帧：长度(uint32) + 类型(byte) + Codec 编码的 remoteMessage
+type frameConn struct {
+	conn    net.Conn
+	codec   Codec
+	reader  *bufio.Reader
+	timeout time.Duration // 读写超时，为 0 时不限制
+
+	writeLock sync.Mutex
+}
+
+func newFrameConn(conn net.Conn, codec Codec, timeout time.Duration) *frameConn {
+	return &frameConn{
+		conn:    conn,
+		codec:   codec,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+}
+
+// write 发送一帧，可以并发调用
+func (c *frameConn) write(typ frameType, msg *remoteMessage) error {
+	var payload []byte
+	if msg != nil {
+		data, err := c.codec.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		payload = data
+	}
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("eventbus: frame of %d bytes exceeds limit", len(payload))
+	}
+
+	buf := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	buf[4] = byte(typ)
+	copy(buf[frameHeaderSize:], payload)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// read 读取一帧，只能在一个 goroutine 中调用
+func (c *frameConn) read() (frameType, *remoteMessage, error) {
+	if c.timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("eventbus: frame of %d bytes exceeds limit", size)
+	}
+	typ := frameType(header[4])
+
+	msg := &remoteMessage{}
+	if size == 0 {
+		return typ, msg, nil
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	if err := c.codec.Unmarshal(payload, msg); err != nil {
+		return 0, nil, err
+	}
+	return typ, msg, nil
+}
+
+func (c *frameConn) close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,310 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrNotConnected RemoteBus 当前没有连接到 Broker，正在重连
+var ErrNotConnected = errors.New("eventbus: not connected to broker")
+
+// heartbeatConfig 心跳配置
+type heartbeatConfig struct {
+	interval time.Duration
+}
+
+// timeout 超过该时间没有收到任何帧即认为连接已断开
+func (h heartbeatConfig) timeout() time.Duration {
+	return 3 * h.interval
+}
+
+// RemoteBus 通过 Broker 与其他进程共享事件的事件总线
+//
+// Subscribe 在本地注册订阅者，并把订阅主题告知 Broker；Publish 把事件发给 Broker，
+// 由 Broker 转发给所有订阅了匹配主题的 RemoteBus（包括自己）。收到的事件在本地按
+// AsyncEventBus 的方式排队分发，队列、worker、中间件等配置同样生效。
+//
+// 连接断开后会按退避策略自动重连，并重新发送所有订阅；断开期间 Publish 返回 ErrNotConnected。
+// 事件参数通过 Codec 编解码，规则与 DurableEventBus 相同。
+type RemoteBus struct {
+	network   string
+	addr      string
+	codec     Codec
+	heartbeat heartbeatConfig
+	reconnect RetryPolicy
+	onError   ErrorHandler
+
+	handlers *registry
+	dispatch *dispatcher
+
+	lock     sync.Mutex
+	conn     *frameConn
+	patterns map[string]int // 每个订阅主题的本地订阅者数量
+	closed   bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewRemoteBus 连接 network/addr 上的 Broker，第一次连接失败时直接返回错误
+func NewRemoteBus(network, addr string, options ...*Option) (*RemoteBus, error) {
+	opts := defaultBusOptions()
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	r := &RemoteBus{
+		network:   network,
+		addr:      addr,
+		codec:     opts.codec,
+		heartbeat: opts.heartbeat,
+		reconnect: opts.reconnect,
+		onError:   opts.onError,
+		handlers:  newRegistry(opts.middlewares),
+		patterns:  map[string]int{},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	r.handlers.onRemove = r.release
+	r.dispatch = newDispatcher(opts, r.run)
+
+	conn, err := r.dial()
+	if err != nil {
+		r.dispatch.close()
+		return nil, err
+	}
+	r.conn = conn
+	go r.loop(conn)
+	return r, nil
+}
+
+func (r *RemoteBus) dial() (*frameConn, error) {
+	conn, err := net.DialTimeout(r.network, r.addr, r.heartbeat.timeout())
+	if err != nil {
+		return nil, err
+	}
+	return newFrameConn(conn, r.codec, r.heartbeat.timeout()), nil
+}
+
+// loop 维护连接：读取事件、发送心跳，断开后重连并重新订阅
+func (r *RemoteBus) loop(conn *frameConn) {
+	defer close(r.done)
+
+	for {
+		r.serve(conn)
+
+		r.lock.Lock()
+		r.conn = nil
+		r.lock.Unlock()
+
+		conn = r.redial()
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// redial 按退避策略重连，成功后重新发送所有订阅；RemoteBus 关闭时返回 nil
+func (r *RemoteBus) redial() *frameConn {
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-r.stop:
+			return nil
+		case <-time.After(r.reconnect.backoff(attempt)):
+		}
+
+		conn, err := r.dial()
+		if err != nil {
+			continue
+		}
+
+		r.lock.Lock()
+		if r.closed {
+			r.lock.Unlock()
+			conn.close()
+			return nil
+		}
+		for topic := range r.patterns {
+			if err = conn.write(frameSubscribe, &remoteMessage{Topic: topic}); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			r.lock.Unlock()
+			conn.close()
+			continue
+		}
+		r.conn = conn
+		r.lock.Unlock()
+		return conn
+	}
+}
+
+// serve 在一个连接上收发帧，直到连接断开
+func (r *RemoteBus) serve(conn *frameConn) {
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go func() {
+		ticker := time.NewTicker(r.heartbeat.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := conn.write(framePing, nil); err != nil {
+					conn.close()
+					return
+				}
+			case <-stopPing:
+				return
+			}
+		}
+	}()
+
+	for {
+		typ, msg, err := conn.read()
+		if err != nil {
+			conn.close()
+			return
+		}
+		if typ == framePublish {
+			r.receive(msg)
+		}
+	}
+}
+
+// receive 按本地订阅者的入参类型解码事件，放入队列等待分发
+func (r *RemoteBus) receive(msg *remoteMessage) {
+	for _, sub := range r.handlers.match(msg.Topic) {
+		args, err := decodeArgs(r.codec, sub.fn.Type(), msg.Args)
+		if err != nil {
+			r.onError(&HandlerError{Topic: msg.Topic, Err: err})
+			continue
+		}
+		err = r.dispatch.enqueue(&task{
+			topic: msg.Topic,
+			args:  args,
+			subs:  []*subscriber{sub},
+		})
+		if err != nil {
+			r.onError(&HandlerError{Topic: msg.Topic, Args: args, Err: err})
+		}
+	}
+}
+
+// run 在 worker 中执行一个事件
+func (r *RemoteBus) run(t *task) {
+	for _, sub := range t.subs {
+		if err := deliver(sub, t.topic, t.args); err != nil {
+			r.onError(err)
+		}
+	}
+}
+
+// send 在当前连接上发送一帧
+func (r *RemoteBus) send(typ frameType, msg *remoteMessage) error {
+	r.lock.Lock()
+	conn := r.conn
+	closed := r.closed
+	r.lock.Unlock()
+
+	if closed {
+		return ErrBusClosed
+	}
+	if conn == nil {
+		return ErrNotConnected
+	}
+	if err := conn.write(typ, msg); err != nil {
+		conn.close()
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// Subscribe 订阅主题，用法与 AsyncEventBus.Subscribe 相同
+func (r *RemoteBus) Subscribe(topic string, handler interface{}, options ...*SubscribeOption) (Subscription, error) {
+	return r.subscribe(topic, handler, false, options)
+}
+
+// SubscribeOnce 订阅主题，handler 只会被触发一次，触发后自动取消订阅
+func (r *RemoteBus) SubscribeOnce(topic string, handler interface{}, options ...*SubscribeOption) (Subscription, error) {
+	return r.subscribe(topic, handler, true, options)
+}
+
+func (r *RemoteBus) subscribe(topic string, handler interface{}, once bool, options []*SubscribeOption) (Subscription, error) {
+	sub, err := r.handlers.subscribe(topic, handler, once, options)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	r.patterns[topic]++
+	first := r.patterns[topic] == 1
+	r.lock.Unlock()
+
+	// 没有连接时，重连成功后会统一重新订阅
+	if first {
+		if err := r.send(frameSubscribe, &remoteMessage{Topic: topic}); err == ErrBusClosed {
+			sub.Unsubscribe()
+			return nil, err
+		}
+	}
+	return sub, nil
+}
+
+// release 本地订阅者被移除，最后一个订阅者移除时通知 Broker
+func (r *RemoteBus) release(sub *subscriber) {
+	r.lock.Lock()
+	r.patterns[sub.topic]--
+	last := r.patterns[sub.topic] <= 0
+	if last {
+		delete(r.patterns, sub.topic)
+	}
+	r.lock.Unlock()
+
+	if last {
+		_ = r.send(frameUnsubscribe, &remoteMessage{Topic: sub.topic})
+	}
+}
+
+// Publish 把事件发给 Broker，发送成功即返回
+func (r *RemoteBus) Publish(topic string, args ...interface{}) error {
+	encoded, err := encodeArgs(r.codec, args)
+	if err != nil {
+		return err
+	}
+	return r.send(framePublish, &remoteMessage{Topic: topic, Args: encoded})
+}
+
+// WaitAsync 阻塞等待所有已收到的事件处理完毕
+func (r *RemoteBus) WaitAsync() {
+	_ = r.dispatch.wait(context.Background())
+}
+
+// Close 断开与 Broker 的连接，并等待已收到的事件处理完毕，ctx 结束时返回 ctx.Err()，
+// 分发器仍会在连接退出后关闭，再次调用 Close 可以继续等待
+func (r *RemoteBus) Close(ctx context.Context) error {
+	r.lock.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.stop)
+		if r.conn != nil {
+			r.conn.close()
+		}
+		// 连接的 goroutine 退出后不会再有新事件，此时才能关闭分发器，
+		// 放在单独的 goroutine 中，ctx 提前结束时 worker 也不会泄漏
+		go func() {
+			<-r.done
+			r.dispatch.close()
+		}()
+	}
+	r.lock.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.dispatch.wait(ctx)
+}
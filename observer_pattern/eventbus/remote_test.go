@@ -0,0 +1,173 @@
+package eventbus
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type stockChanged struct {
+	SKU   string
+	Stock int
+}
+
+func startBroker(t *testing.T, network, addr string, options ...*Option) (*Broker, string) {
+	t.Helper()
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	broker := NewBroker(options...)
+	go broker.Serve(l)
+	return broker, l.Addr().String()
+}
+
+func newRemoteBus(t *testing.T, network, addr string, options ...*Option) *RemoteBus {
+	t.Helper()
+	bus, err := NewRemoteBus(network, addr, options...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { bus.Close(context.Background()) })
+	return bus
+}
+
+// publishUntil 重复发布直到 received 收到事件，用于等待订阅在 Broker 上生效
+func publishUntil(t *testing.T, bus *RemoteBus, received <-chan stockChanged, topic string, event stockChanged) stockChanged {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		bus.Publish(topic, context.Background(), event)
+		select {
+		case got := <-received:
+			return got
+		case <-ticker.C:
+		case <-deadline:
+			t.Fatal("event not received")
+		}
+	}
+}
+
+func TestRemoteBus_PublishSubscribe(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		addr    string
+		codec   Codec
+	}{
+		{"tcp-json", "tcp", "127.0.0.1:0", JSONCodec},
+		{"unix-gob", "unix", filepath.Join(t.TempDir(), "bus.sock"), GobCodec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker, addr := startBroker(t, tt.network, tt.addr, WithCodec(tt.codec))
+			defer broker.Close()
+
+			inventory := newRemoteBus(t, tt.network, addr, WithCodec(tt.codec))
+			shop := newRemoteBus(t, tt.network, addr, WithCodec(tt.codec))
+
+			received := make(chan stockChanged, 10)
+			inventory.Subscribe("stock:*", func(ctx context.Context, e stockChanged) {
+				received <- e
+			})
+
+			got := publishUntil(t, shop, received, "stock:changed", stockChanged{SKU: "apple", Stock: 3})
+			if got.SKU != "apple" || got.Stock != 3 {
+				t.Fatalf("unexpected event: %+v", got)
+			}
+
+			// 事件不会发给没有订阅匹配主题的进程
+			if err := shop.Publish("order:paid", context.Background(), stockChanged{}); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoteBus_ReconnectAndResubscribe(t *testing.T) {
+	heartbeat := WithHeartbeat(20 * time.Millisecond)
+	backoff := WithReconnectBackoff(10*time.Millisecond, 50*time.Millisecond)
+
+	broker, addr := startBroker(t, "tcp", "127.0.0.1:0", heartbeat)
+	bus := newRemoteBus(t, "tcp", addr, heartbeat, backoff)
+
+	received := make(chan stockChanged, 10)
+	bus.Subscribe("stock:changed", func(ctx context.Context, e stockChanged) {
+		received <- e
+	})
+	publishUntil(t, bus, received, "stock:changed", stockChanged{SKU: "before"})
+
+	// 重启 Broker，RemoteBus 应该自动重连并重新订阅
+	broker.Close()
+	waitUntil(t, func() bool {
+		return bus.Publish("stock:changed", context.Background(), stockChanged{}) == ErrNotConnected
+	})
+	broker, _ = startBroker(t, "tcp", addr, heartbeat)
+	defer broker.Close()
+
+	for len(received) > 0 {
+		<-received
+	}
+	got := publishUntil(t, bus, received, "stock:changed", stockChanged{SKU: "after"})
+	if got.SKU != "after" {
+		t.Fatalf("unexpected event after reconnect: %+v", got)
+	}
+}
+
+func TestRemoteBus_Unsubscribe(t *testing.T) {
+	broker, addr := startBroker(t, "tcp", "127.0.0.1:0")
+	defer broker.Close()
+	bus := newRemoteBus(t, "tcp", addr)
+
+	received := make(chan stockChanged, 10)
+	sub, _ := bus.Subscribe("stock:changed", func(ctx context.Context, e stockChanged) {
+		received <- e
+	})
+	publishUntil(t, bus, received, "stock:changed", stockChanged{})
+
+	sub.Unsubscribe()
+	waitUntil(t, func() bool {
+		broker.lock.Lock()
+		defer broker.lock.Unlock()
+		return len(broker.owners) == 0
+	})
+
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := bus.Publish("stock:changed", context.Background(), stockChanged{}); err != ErrBusClosed {
+		t.Fatalf("expected ErrBusClosed, got %v", err)
+	}
+}
+
+func TestRemoteBus_CloseDeadline(t *testing.T) {
+	broker, addr := startBroker(t, "tcp", "127.0.0.1:0")
+	defer broker.Close()
+
+	before := runtime.NumGoroutine()
+	bus, err := NewRemoteBus("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	received := make(chan stockChanged, 10)
+	bus.Subscribe("stock:changed", func(ctx context.Context, e stockChanged) {
+		received <- e
+	})
+	publishUntil(t, bus, received, "stock:changed", stockChanged{SKU: "apple"})
+
+	// ctx 已经结束时 Close 可能提前返回，分发器仍然要关闭，再次 Close 等到关闭完成
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bus.Close(ctx); err != nil && err != context.Canceled {
+		t.Fatalf("expected nil or Canceled, got %v", err)
+	}
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("close again: %v", err)
+	}
+	assertNoGoroutineLeak(t, before)
+}
@@ -0,0 +1,170 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// inboxPrefix 回复主题的前缀，每次请求使用一个独立的回复主题
+const inboxPrefix = "_inbox" + TopicSeparator
+
+// ErrInsufficientReplies Gather 在 ctx 结束前没有收到足够的回复
+var ErrInsufficientReplies = errors.New("eventbus: insufficient replies")
+
+// RemoteError 响应者返回的错误
+type RemoteError struct {
+	Topic   string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("eventbus: responder of %s failed: %s", e.Topic, e.Message)
+}
+
+// requestMessage 发布给响应者的请求
+type requestMessage[Req any] struct {
+	ID      string
+	ReplyTo string
+	Payload Req
+}
+
+// replyMessage 响应者发回的回复
+type replyMessage[Resp any] struct {
+	ID      string
+	Payload Resp
+	Error   string
+}
+
+// Respond 在 topic 上注册响应者，handler 的返回值会发布到请求方的回复主题
+func Respond[Req, Resp any](bus Bus, topic string, handler func(ctx context.Context, req Req) (Resp, error), options ...*SubscribeOption) (Subscription, error) {
+	return bus.Subscribe(topic, func(ctx context.Context, req requestMessage[Req]) error {
+		resp, err := handler(ctx, req.Payload)
+		reply := replyMessage[Resp]{ID: req.ID, Payload: resp}
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		return bus.Publish(req.ReplyTo, ctx, reply)
+	}, options...)
+}
+
+// inbox 收集一次请求的回复
+type inbox[Resp any] struct {
+	lock    sync.Mutex
+	replies []replyMessage[Resp]
+	notify  chan struct{}
+}
+
+func (in *inbox[Resp]) push(reply replyMessage[Resp]) {
+	in.lock.Lock()
+	in.replies = append(in.replies, reply)
+	in.lock.Unlock()
+
+	select {
+	case in.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (in *inbox[Resp]) take() []replyMessage[Resp] {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+
+	replies := in.replies
+	in.replies = nil
+	return replies
+}
+
+// scatter 订阅一个新的回复主题，再把请求发布到 topic，每收到一批回复调用一次 collect，
+// collect 返回 true 或 ctx 结束时停止
+func scatter[Req, Resp any](ctx context.Context, bus Bus, topic string, req Req, collect func([]replyMessage[Resp]) bool) error {
+	id := newTraceID()
+	replyTo := inboxPrefix + id
+	in := &inbox[Resp]{notify: make(chan struct{}, 1)}
+
+	sub, err := bus.Subscribe(replyTo, func(ctx context.Context, reply replyMessage[Resp]) {
+		if reply.ID == id {
+			in.push(reply)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	if err := bus.Publish(topic, ctx, requestMessage[Req]{ID: id, ReplyTo: replyTo, Payload: req}); err != nil {
+		return err
+	}
+
+	for {
+		if collect(in.take()) {
+			return nil
+		}
+		select {
+		case <-in.notify:
+		case <-ctx.Done():
+			// 同步总线或并发到达的回复可能在 ctx 结束前已经放入 inbox
+			if collect(in.take()) {
+				return nil
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// Request 发布请求并等待第一个回复，没有响应者时一直等到 ctx 结束
+//
+// 在 AsyncEventBus 上，回复同样需要 worker 来分发，响应者耗时较长时需要适当增加 worker 数量。
+func Request[Req, Resp any](ctx context.Context, bus Bus, topic string, req Req) (Resp, error) {
+	var first *replyMessage[Resp]
+	err := scatter(ctx, bus, topic, req, func(replies []replyMessage[Resp]) bool {
+		if len(replies) > 0 {
+			first = &replies[0]
+		}
+		return first != nil
+	})
+
+	var zero Resp
+	if err != nil {
+		return zero, err
+	}
+	if first.Error != "" {
+		return zero, &RemoteError{Topic: topic, Message: first.Error}
+	}
+	return first.Payload, nil
+}
+
+// Gather 发布请求并收集多个响应者的回复
+//
+// 收到 minReplies 个成功的回复后立即返回；minReplies 不大于 0 时一直收集到 ctx 结束，
+// 此时 ctx 结束不视为错误。ctx 结束时成功的回复不足 minReplies 个，
+// 返回已经收到的回复和包含 ErrInsufficientReplies、ctx.Err() 以及各响应者错误的 error。
+func Gather[Req, Resp any](ctx context.Context, bus Bus, topic string, req Req, minReplies int) ([]Resp, error) {
+	var results []Resp
+	var errs []error
+	err := scatter(ctx, bus, topic, req, func(replies []replyMessage[Resp]) bool {
+		for _, reply := range replies {
+			if reply.Error != "" {
+				errs = append(errs, &RemoteError{Topic: topic, Message: reply.Error})
+				continue
+			}
+			results = append(results, reply.Payload)
+		}
+		return minReplies > 0 && len(results) >= minReplies
+	})
+
+	switch {
+	case err == nil:
+		return results, nil
+	case minReplies <= 0 && err == ctx.Err():
+		return results, nil
+	case err != ctx.Err():
+		return results, err
+	}
+	errs = append([]error{
+		fmt.Errorf("%w: got %d of %d", ErrInsufficientReplies, len(results), minReplies),
+		err,
+	}, errs...)
+	return results, errors.Join(errs...)
+}
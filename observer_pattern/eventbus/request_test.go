@@ -0,0 +1,104 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+type stockQuery struct {
+	SKU string
+}
+
+type stockReply struct {
+	Shard int
+	Stock int
+}
+
+func TestRequest(t *testing.T) {
+	for _, mode := range []Mode{ModeAsync, ModeSync} {
+		t.Run(string(mode), func(t *testing.T) {
+			bus, _ := NewBus(mode)
+			defer bus.Close(context.Background())
+
+			Respond(bus, "stock:query", func(ctx context.Context, q stockQuery) (stockReply, error) {
+				if q.SKU == "" {
+					return stockReply{}, errors.New("empty sku")
+				}
+				return stockReply{Stock: len(q.SKU)}, nil
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			reply, err := Request[stockQuery, stockReply](ctx, bus, "stock:query", stockQuery{SKU: "apple"})
+			if err != nil || reply.Stock != 5 {
+				t.Fatalf("unexpected reply: %+v, %v", reply, err)
+			}
+
+			_, err = Request[stockQuery, stockReply](ctx, bus, "stock:query", stockQuery{})
+			var remote *RemoteError
+			if !errors.As(err, &remote) || remote.Message != "empty sku" {
+				t.Fatalf("expected RemoteError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRequest_Timeout(t *testing.T) {
+	bus := NewAsyncEventBus()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := Request[stockQuery, stockReply](ctx, bus, "stock:query", stockQuery{SKU: "apple"})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGather(t *testing.T) {
+	// 慢分片会占住一个 worker，需要其他 worker 处理回复
+	bus := NewAsyncEventBus(WithWorkers(4))
+	for shard := 0; shard < 3; shard++ {
+		shard := shard
+		Respond(bus, "stock:query", func(ctx context.Context, q stockQuery) (stockReply, error) {
+			if shard == 2 {
+				// 第 3 个分片很慢
+				select {
+				case <-time.After(time.Second):
+				case <-ctx.Done():
+					return stockReply{}, ctx.Err()
+				}
+			}
+			return stockReply{Shard: shard, Stock: 10 * shard}, nil
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	replies, err := Gather[stockQuery, stockReply](ctx, bus, "stock:query", stockQuery{SKU: "apple"}, 2)
+	if err != nil || len(replies) != 2 {
+		t.Fatalf("expected 2 replies, got %v, %v", replies, err)
+	}
+	sort.Slice(replies, func(i, j int) bool { return replies[i].Shard < replies[j].Shard })
+	if replies[0].Shard != 0 || replies[1].Stock != 10 {
+		t.Fatalf("unexpected replies: %+v", replies)
+	}
+
+	// 要求 3 个回复，但只有 2 个分片在超时前回复
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	replies, err = Gather[stockQuery, stockReply](ctx, bus, "stock:query", stockQuery{SKU: "apple"}, 3)
+	if !errors.Is(err, ErrInsufficientReplies) || !errors.Is(err, context.DeadlineExceeded) || len(replies) != 2 {
+		t.Fatalf("expected partial result, got %v, %v", replies, err)
+	}
+
+	// minReplies 为 0 时收集到超时为止
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	replies, err = Gather[stockQuery, stockReply](ctx, bus, "stock:query", stockQuery{SKU: "apple"}, 0)
+	if err != nil || len(replies) != 2 {
+		t.Fatalf("expected all fast replies, got %v, %v", replies, err)
+	}
+}
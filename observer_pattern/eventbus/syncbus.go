@@ -0,0 +1,131 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// PublishError 同步发布时所有失败的订阅者
+type PublishError struct {
+	Topic  string
+	Errors []*HandlerError
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("eventbus: %d handler(s) failed in topic %s, first: %v", len(e.Errors), e.Topic, e.Errors[0].Err)
+}
+
+// Unwrap 支持 errors.Is / errors.As 逐个检查订阅者的错误
+func (e *PublishError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// SyncEventBus 同步事件总线
+//
+// Publish 在调用方的 goroutine 中按订阅顺序依次执行所有匹配的订阅者，
+// 全部执行完才返回，失败的订阅者通过 *PublishError 一并返回，不再交给 ErrorHandler。
+// 队列、worker 相关的配置对同步总线无效。
+type SyncEventBus struct {
+	handlers   *registry
+	deadLetter DeadLetterHandler
+
+	lock     sync.Mutex
+	idle     *sync.Cond // 正在进行的 Publish 全部返回
+	closed   bool
+	inflight int
+}
+
+// NewSyncEventBus 创建一个新的同步事件总线
+func NewSyncEventBus(options ...*Option) *SyncEventBus {
+	opts := defaultBusOptions()
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	s := &SyncEventBus{
+		handlers:   newRegistry(opts.middlewares),
+		deadLetter: opts.deadLetter,
+	}
+	s.idle = sync.NewCond(&s.lock)
+	return s
+}
+
+// Subscribe 订阅主题，用法与 AsyncEventBus.Subscribe 相同
+func (s *SyncEventBus) Subscribe(topic string, handler interface{}, options ...*SubscribeOption) (Subscription, error) {
+	return s.handlers.subscribe(topic, handler, false, options)
+}
+
+// SubscribeOnce 订阅主题，handler 只会被触发一次，触发后自动取消订阅
+func (s *SyncEventBus) SubscribeOnce(topic string, handler interface{}, options ...*SubscribeOption) (Subscription, error) {
+	return s.handlers.subscribe(topic, handler, true, options)
+}
+
+// Publish 发布事件，所有订阅者执行完毕后返回
+func (s *SyncEventBus) Publish(topic string, args ...interface{}) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return ErrBusClosed
+	}
+	s.inflight++
+	s.lock.Unlock()
+	defer s.done()
+
+	handlers := s.handlers.match(topic)
+	if len(handlers) == 0 {
+		if s.deadLetter != nil {
+			s.deadLetter(topic, args)
+		}
+		return nil
+	}
+
+	var errs []*HandlerError
+	for _, sub := range handlers {
+		if err := deliver(sub, topic, args); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &PublishError{Topic: topic, Errors: errs}
+	}
+	return nil
+}
+
+// Close 关闭事件总线，之后的 Publish 都会返回 ErrBusClosed，
+// 并等待正在进行的 Publish 返回，ctx 结束时返回 ctx.Err()
+func (s *SyncEventBus) Close(ctx context.Context) error {
+	// ctx 结束时唤醒等待者，避免在 Cond 上永远阻塞
+	stop := context.AfterFunc(ctx, func() {
+		s.lock.Lock()
+		s.idle.Broadcast()
+		s.lock.Unlock()
+	})
+	defer stop()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	for s.inflight > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.idle.Wait()
+	}
+	return nil
+}
+
+// done 标记一次 Publish 结束
+func (s *SyncEventBus) done() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.inflight--
+	if s.inflight == 0 {
+		s.idle.Broadcast()
+	}
+}
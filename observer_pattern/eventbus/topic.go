@@ -0,0 +1,39 @@
+package eventbus
+
+import "context"
+
+// Topic 强类型主题
+//
+// 与直接调用 Bus.Subscribe 不同，Topic 的订阅函数签名在编译期就确定了，
+// 不会再出现发布参数与订阅函数不匹配导致的运行时错误。
+// 底层仍然复用 Bus 进行分发，可以和旧的反射式订阅共存。
+type Topic[T any] struct {
+	bus  Bus
+	name string
+}
+
+// NewTopic 在 bus 上创建一个事件类型为 T 的主题
+func NewTopic[T any](bus Bus, name string) *Topic[T] {
+	return &Topic[T]{
+		bus:  bus,
+		name: name,
+	}
+}
+
+// Name 主题名称
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Subscribe 订阅主题
+func (t *Topic[T]) Subscribe(handler func(ctx context.Context, event T) error, options ...*SubscribeOption) (Subscription, error) {
+	return t.bus.Subscribe(t.name, handler, options...)
+}
+
+// Publish 发布事件
+func (t *Topic[T]) Publish(ctx context.Context, event T) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return t.bus.Publish(t.name, ctx, event)
+}
@@ -0,0 +1,138 @@
+package eventbus
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// TopicSeparator 主题分段的分隔符，如 "order:paid"
+	TopicSeparator = ":"
+	// SingleWildcard 匹配一个分段，如 "order:*" 匹配 "order:paid"，不匹配 "order:paid:1"
+	SingleWildcard = "*"
+	// MultiWildcard 匹配零个或多个分段，如 "game:#" 匹配 "game"、"game:start"、"game:player:1"
+	MultiWildcard = "#"
+)
+
+// trieNode 主题前缀树的节点，每一层对应主题的一个分段
+type trieNode struct {
+	children map[string]*trieNode
+	subs     []*subscriber
+}
+
+// topicTrie 按分段存储订阅者，匹配耗时只与主题分段数和通配符数量有关，与订阅总数无关
+type topicTrie struct {
+	root *trieNode
+}
+
+func newTopicTrie() *topicTrie {
+	return &topicTrie{root: &trieNode{}}
+}
+
+// add 添加订阅者
+func (t *topicTrie) add(sub *subscriber) {
+	node := t.root
+	for _, seg := range strings.Split(sub.topic, TopicSeparator) {
+		if node.children == nil {
+			node.children = map[string]*trieNode{}
+		}
+		child, ok := node.children[seg]
+		if !ok {
+			child = &trieNode{}
+			node.children[seg] = child
+		}
+		node = child
+	}
+	node.subs = append(node.subs, sub)
+}
+
+// remove 移除订阅者，并清理空节点
+func (t *topicTrie) remove(sub *subscriber) {
+	t.root.remove(strings.Split(sub.topic, TopicSeparator), sub)
+}
+
+func (n *trieNode) remove(segs []string, sub *subscriber) {
+	if len(segs) == 0 {
+		for i, s := range n.subs {
+			if s == sub {
+				n.subs = append(n.subs[:i:i], n.subs[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+
+	child, ok := n.children[segs[0]]
+	if !ok {
+		return
+	}
+	child.remove(segs[1:], sub)
+	if len(child.subs) == 0 && len(child.children) == 0 {
+		delete(n.children, segs[0])
+	}
+}
+
+// match 找出所有匹配 topic 的订阅者，按订阅先后排序
+func (t *topicTrie) match(topic string) []*subscriber {
+	var subs []*subscriber
+	t.root.collect(strings.Split(topic, TopicSeparator), &subs)
+	if len(subs) < 2 {
+		return subs
+	}
+
+	// 多个 # 可能匹配到同一个节点，这里顺便去重
+	sort.Slice(subs, func(i, j int) bool { return subs[i].id < subs[j].id })
+	uniq := subs[:1]
+	for _, sub := range subs[1:] {
+		if sub != uniq[len(uniq)-1] {
+			uniq = append(uniq, sub)
+		}
+	}
+	return uniq
+}
+
+func (n *trieNode) collect(segs []string, subs *[]*subscriber) {
+	if child, ok := n.children[MultiWildcard]; ok {
+		for i := 0; i <= len(segs); i++ {
+			child.collect(segs[i:], subs)
+		}
+	}
+	if len(segs) == 0 {
+		*subs = append(*subs, n.subs...)
+		return
+	}
+	if child, ok := n.children[segs[0]]; ok {
+		child.collect(segs[1:], subs)
+	}
+	if segs[0] != SingleWildcard {
+		if child, ok := n.children[SingleWildcard]; ok {
+			child.collect(segs[1:], subs)
+		}
+	}
+}
+
+// topicMatches 判断单个订阅主题是否匹配 topic，规则与 topicTrie 相同
+func topicMatches(pattern, topic string) bool {
+	return segmentsMatch(strings.Split(pattern, TopicSeparator), strings.Split(topic, TopicSeparator))
+}
+
+func segmentsMatch(pattern, topic []string) bool {
+	if len(pattern) == 0 {
+		return len(topic) == 0
+	}
+	if pattern[0] == MultiWildcard {
+		for i := 0; i <= len(topic); i++ {
+			if segmentsMatch(pattern[1:], topic[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(topic) == 0 {
+		return false
+	}
+	if pattern[0] != SingleWildcard && pattern[0] != topic[0] {
+		return false
+	}
+	return segmentsMatch(pattern[1:], topic[1:])
+}
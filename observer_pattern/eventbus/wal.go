@@ -0,0 +1,349 @@
+package eventbus
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentExt        = ".wal"
+	recordHeaderSize  = 8 // 4 字节长度 + 4 字节 crc32
+	maxRecordSize     = 64 << 20
+	segmentNameDigits = 20
+)
+
+// errCaughtUp 已经读到日志末尾
+var errCaughtUp = errors.New("eventbus: wal caught up")
+
+// walRecord 日志中的一条事件
+type walRecord struct {
+	Offset uint64       `json:"offset"`
+	Topic  string       `json:"topic"`
+	Args   []encodedArg `json:"args"`
+}
+
+// segment 日志分段文件，文件名是该分段第一条记录的 offset
+type segment struct {
+	base uint64
+	path string
+}
+
+// wal 分段存储的预写日志，只追加不修改
+//
+// 每条记录的格式为：长度(uint32) + crc32(uint32) + JSON 编码的 walRecord。
+type wal struct {
+	dir         string
+	segmentSize int64
+	syncWrites  bool
+
+	mu         sync.Mutex
+	segments   []segment
+	active     *os.File
+	activeSize int64
+	next       uint64 // 下一条记录的 offset，小于它的记录都已完整写入
+}
+
+// openWAL 打开 dir 下的日志，最后一个分段末尾不完整的记录会被截断
+func openWAL(dir string, segmentSize int64, syncWrites bool) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &wal{
+		dir:         dir,
+		segmentSize: segmentSize,
+		syncWrites:  syncWrites,
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		w.segments = append(w.segments, segment{base: base, path: filepath.Join(dir, name)})
+	}
+	sort.Slice(w.segments, func(i, j int) bool { return w.segments[i].base < w.segments[j].base })
+
+	if len(w.segments) == 0 {
+		if err := w.roll(0); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	if err := w.recover(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// recover 扫描最后一个分段，找到下一条记录的 offset，并截断不完整的尾部
+func (w *wal) recover() error {
+	last := w.segments[len(w.segments)-1]
+	f, err := os.OpenFile(last.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w.next = last.base
+	var size int64
+	for {
+		rec, n, err := readRecord(f)
+		if err != nil {
+			// io.EOF 是正常结束，其余错误说明进程在写入时崩溃，丢弃尾部
+			break
+		}
+		w.next = rec.Offset + 1
+		size += n
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	w.active = f
+	w.activeSize = size
+	return nil
+}
+
+// roll 创建以 base 开头的新分段作为当前写入的分段，调用方需持有锁
+func (w *wal) roll(base uint64) error {
+	path := filepath.Join(w.dir, fmt.Sprintf("%0*d%s", segmentNameDigits, base, segmentExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if w.active != nil {
+		w.active.Close()
+	}
+	w.segments = append(w.segments, segment{base: base, path: path})
+	w.active = f
+	w.activeSize = 0
+	return nil
+}
+
+// append 追加一条记录，返回记录的 offset
+func (w *wal) append(topic string, args []encodedArg) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.active == nil {
+		return 0, ErrBusClosed
+	}
+
+	rec := walRecord{Offset: w.next, Topic: topic, Args: args}
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	if len(payload) > maxRecordSize {
+		return 0, fmt.Errorf("eventbus: record of %d bytes exceeds limit", len(payload))
+	}
+
+	if w.activeSize > 0 && w.activeSize+int64(len(payload))+recordHeaderSize > w.segmentSize {
+		if err := w.roll(w.next); err != nil {
+			return 0, err
+		}
+	}
+
+	buf := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[recordHeaderSize:], payload)
+	if _, err := w.active.Write(buf); err != nil {
+		return 0, err
+	}
+	if w.syncWrites {
+		if err := w.active.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	w.activeSize += int64(len(buf))
+	w.next++
+	return rec.Offset, nil
+}
+
+// committed 下一条记录的 offset
+func (w *wal) committed() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.next
+}
+
+// segmentFor 找到包含 offset 的分段；offset 所在分段已被压缩时返回最早的分段
+func (w *wal) segmentFor(offset uint64) segment {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := sort.Search(len(w.segments), func(i int) bool { return w.segments[i].base > offset })
+	if i == 0 {
+		return w.segments[0]
+	}
+	return w.segments[i-1]
+}
+
+// segmentAfter 找到 base 之后的下一个分段
+func (w *wal) segmentAfter(base uint64) (segment, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, seg := range w.segments {
+		if seg.base > base {
+			return seg, true
+		}
+	}
+	return segment{}, false
+}
+
+// compact 删除所有记录的 offset 都小于 offset 的分段，当前写入的分段不会被删除
+func (w *wal) compact(offset uint64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	removed := 0
+	for len(w.segments) > 1 && w.segments[1].base <= offset {
+		if err := os.Remove(w.segments[0].path); err != nil {
+			return removed, err
+		}
+		w.segments = w.segments[1:]
+		removed++
+	}
+	return removed, nil
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.active == nil {
+		return nil
+	}
+	// 未开启 syncWrites 时，关闭前把写入的数据刷到磁盘
+	err := w.active.Sync()
+	if cerr := w.active.Close(); err == nil {
+		err = cerr
+	}
+	w.active = nil
+	return err
+}
+
+// readRecord 从 r 中读取一条记录，返回记录和它占用的字节数
+func readRecord(r io.Reader) (*walRecord, int64, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return nil, 0, fmt.Errorf("eventbus: corrupted record size %d", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errors.New("eventbus: corrupted record checksum")
+	}
+
+	rec := &walRecord{}
+	if err := json.Unmarshal(payload, rec); err != nil {
+		return nil, 0, err
+	}
+	return rec, int64(recordHeaderSize) + int64(size), nil
+}
+
+// walReader 从指定 offset 开始顺序读取日志
+type walReader struct {
+	w      *wal
+	mu     sync.Mutex
+	offset uint64 // 下一条要读取的 offset
+	seg    segment
+	file   *os.File
+}
+
+func newWALReader(w *wal, offset uint64) *walReader {
+	return &walReader{w: w, offset: offset}
+}
+
+// position 下一条要读取的 offset
+func (r *walReader) position() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.offset
+}
+
+// next 读取下一条记录，已经读到末尾时返回 errCaughtUp
+func (r *walReader) next() (*walRecord, error) {
+	for {
+		if r.offset >= r.w.committed() {
+			return nil, errCaughtUp
+		}
+
+		if r.file == nil {
+			r.seg = r.w.segmentFor(r.offset)
+			f, err := os.Open(r.seg.path)
+			if err != nil {
+				return nil, err
+			}
+			r.file = f
+		}
+
+		rec, _, err := readRecord(r.file)
+		if err == io.EOF {
+			// 当前分段读完，切换到下一个分段
+			seg, ok := r.w.segmentAfter(r.seg.base)
+			if !ok {
+				return nil, errCaughtUp
+			}
+			r.file.Close()
+			f, err := os.Open(seg.path)
+			if err != nil {
+				r.file = nil
+				return nil, err
+			}
+			r.seg, r.file = seg, f
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Offset < r.offset {
+			continue
+		}
+		return rec, nil
+	}
+}
+
+// advance 标记 rec 已经处理完
+func (r *walReader) advance(rec *walRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.offset = rec.Offset + 1
+}
+
+func (r *walReader) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
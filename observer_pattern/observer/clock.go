@@ -0,0 +1,111 @@
+package observer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock 时间来源，测试时可以替换为 VirtualClock
+type Clock interface {
+	Now() time.Time
+	// AfterFunc 在 d 之后调用 f
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer AfterFunc 返回的定时器
+type Timer interface {
+	// Stop 取消定时器，定时器已经触发或已经取消时返回 false
+	Stop() bool
+}
+
+// SystemClock 系统时钟
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// VirtualClock 手动推进的虚拟时钟，定时器在 Advance 中同步触发
+type VirtualClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*virtualTimer // 按触发时间排序
+}
+
+// NewVirtualClock 创建从 start 开始的虚拟时钟
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+// virtualTimer 虚拟时钟上的定时器
+type virtualTimer struct {
+	clock *VirtualClock
+	when  time.Time
+	seq   uint64
+	f     func()
+}
+
+func (t *virtualTimer) Stop() bool {
+	c := t.clock
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Now 当前的虚拟时间
+func (c *VirtualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// AfterFunc 注册定时器，d 不大于 0 时在下一次 Advance 中触发
+func (c *VirtualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.seq++
+	t := &virtualTimer{clock: c, when: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		if c.timers[i].when.Equal(c.timers[j].when) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	return t
+}
+
+// Advance 把时间推进 d，并按顺序触发这段时间内到期的定时器，
+// 定时器回调中新注册且在这段时间内到期的定时器同样会被触发
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	target := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].when.After(target) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		c.lock.Unlock()
+		t.f()
+		c.lock.Lock()
+	}
+	c.now = target
+	c.lock.Unlock()
+}
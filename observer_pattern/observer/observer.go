@@ -0,0 +1,280 @@
+package observer
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Observer 订阅者
+type Observer[T any] interface {
+	Update(value T)
+}
+
+// ObserverFunc 把普通函数适配为 Observer
+type ObserverFunc[T any] func(value T)
+
+// Update 调用函数本身
+func (f ObserverFunc[T]) Update(value T) {
+	f(value)
+}
+
+// Subject 发布者
+type Subject[T any] interface {
+	// Subscribe 添加订阅者，返回的 Subscription 可用于取消订阅
+	Subscribe(observer Observer[T], options ...*SubscribeOption) Subscription
+	// Unsubscribe 移除订阅者，ObserverFunc 等不可比较的订阅者只能通过 Subscription 取消
+	Unsubscribe(observer Observer[T])
+	// Notify 按优先级依次通知所有订阅者
+	Notify(value T)
+	// NotifyAsync 在后台按优先级依次通知所有订阅者，ctx 结束后不再通知剩余的订阅者，
+	// 返回的 channel 在通知结束后关闭
+	NotifyAsync(ctx context.Context, value T) <-chan struct{}
+}
+
+// Subscription 订阅句柄
+type Subscription interface {
+	// Unsubscribe 取消订阅，可重复调用
+	Unsubscribe()
+}
+
+// subscribeOption 订阅时的可选配置项
+type subscribeOption struct {
+	priority int
+	ttl      time.Duration
+	ctx      context.Context
+}
+
+// SubscribeOption 订阅配置
+type SubscribeOption struct {
+	apply func(*subscribeOption)
+}
+
+// WithPriority 设置订阅者的优先级，数值越大越先被通知，相同优先级按订阅顺序通知
+func WithPriority(priority int) *SubscribeOption {
+	return &SubscribeOption{
+		apply: func(option *subscribeOption) {
+			option.priority = priority
+		},
+	}
+}
+
+// WithTTL 订阅在 ttl 之后自动过期
+func WithTTL(ttl time.Duration) *SubscribeOption {
+	return &SubscribeOption{
+		apply: func(option *subscribeOption) {
+			option.ttl = ttl
+		},
+	}
+}
+
+// WithContext 订阅在 ctx 结束时自动取消，通常传入订阅者所在组件的生命周期 ctx，
+// 组件忘记取消订阅也不会一直收到通知
+func WithContext(ctx context.Context) *SubscribeOption {
+	return &SubscribeOption{
+		apply: func(option *subscribeOption) {
+			option.ctx = ctx
+		},
+	}
+}
+
+// subscription 一个订阅
+type subscription[T any] struct {
+	subject  *SubjectImpl[T]
+	observer Observer[T]
+	priority int
+	seq      uint64
+	expireAt time.Time // 为零值时不过期
+	removed  atomic.Bool
+
+	mu   sync.Mutex
+	stop func() bool // 解除与 ctx 的关联，ctx 已结束时 AfterFunc 可能在另一个 goroutine 中立即执行 Unsubscribe
+}
+
+func (s *subscription[T]) Unsubscribe() {
+	if s.removed.CompareAndSwap(false, true) {
+		s.mu.Lock()
+		stop := s.stop
+		s.mu.Unlock()
+		if stop != nil {
+			stop()
+		}
+		s.subject.remove(s)
+	}
+}
+
+// bind 订阅在 ctx 结束时自动取消
+func (s *subscription[T]) bind(ctx context.Context) {
+	stop := context.AfterFunc(ctx, s.Unsubscribe)
+	s.mu.Lock()
+	s.stop = stop
+	s.mu.Unlock()
+	// 设置 stop 之前已经取消订阅的，这里解除关联
+	if s.removed.Load() {
+		stop()
+	}
+}
+
+func (s *subscription[T]) expired(now time.Time) bool {
+	return !s.expireAt.IsZero() && !now.Before(s.expireAt)
+}
+
+// SubjectImpl 实现 Subject 接口，可以直接使用，也可以嵌入到其他结构体中
+type SubjectImpl[T any] struct {
+	lock          sync.Mutex
+	subscriptions []*subscription[T] // 按通知顺序排列，只会整体替换
+	seq           uint64
+	clock         Clock
+}
+
+// subjectOption 发布者的可选配置项
+type subjectOption struct {
+	clock Clock
+}
+
+// Option 发布者配置
+type Option struct {
+	apply func(*subjectOption)
+}
+
+// WithClock 设置判断订阅是否过期使用的时钟，默认 SystemClock
+func WithClock(clock Clock) *Option {
+	return &Option{
+		apply: func(option *subjectOption) {
+			option.clock = clock
+		},
+	}
+}
+
+// NewSubject 创建发布者
+func NewSubject[T any](options ...*Option) *SubjectImpl[T] {
+	opts := &subjectOption{clock: SystemClock}
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+	return &SubjectImpl[T]{clock: opts.clock}
+}
+
+func (s *SubjectImpl[T]) now() time.Time {
+	if s.clock == nil {
+		return SystemClock.Now()
+	}
+	return s.clock.Now()
+}
+
+// Subscribe 添加订阅者
+func (s *SubjectImpl[T]) Subscribe(observer Observer[T], options ...*SubscribeOption) Subscription {
+	opts := &subscribeOption{}
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	sub := &subscription[T]{
+		subject:  s,
+		observer: observer,
+		priority: opts.priority,
+	}
+	if opts.ttl > 0 {
+		sub.expireAt = s.now().Add(opts.ttl)
+	}
+
+	s.lock.Lock()
+	s.seq++
+	sub.seq = s.seq
+	subs := make([]*subscription[T], 0, len(s.subscriptions)+1)
+	subs = append(subs, s.subscriptions...)
+	subs = append(subs, sub)
+	sort.SliceStable(subs, func(i, j int) bool { return subs[i].priority > subs[j].priority })
+	s.subscriptions = subs
+	s.lock.Unlock()
+
+	if opts.ctx != nil {
+		sub.bind(opts.ctx)
+	}
+	return sub
+}
+
+// Unsubscribe 移除订阅者
+func (s *SubjectImpl[T]) Unsubscribe(observer Observer[T]) {
+	for _, sub := range s.snapshot() {
+		if sameObserver(sub.observer, observer) {
+			sub.Unsubscribe()
+		}
+	}
+}
+
+// sameObserver 判断是否是同一个订阅者，不可比较的类型总是返回 false
+func sameObserver[T any](a, b Observer[T]) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb || ta == nil || !ta.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+func (s *SubjectImpl[T]) remove(sub *subscription[T]) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	subs := make([]*subscription[T], 0, len(s.subscriptions))
+	for _, other := range s.subscriptions {
+		if other != sub {
+			subs = append(subs, other)
+		}
+	}
+	s.subscriptions = subs
+}
+
+func (s *SubjectImpl[T]) snapshot() []*subscription[T] {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.subscriptions
+}
+
+// Len 当前订阅者数量，不包含已过期但还没有被清理的订阅
+func (s *SubjectImpl[T]) Len() int {
+	now := s.now()
+	n := 0
+	for _, sub := range s.snapshot() {
+		if !sub.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Notify 按优先级依次通知所有订阅者，顺便清理已过期的订阅
+func (s *SubjectImpl[T]) Notify(value T) {
+	s.notifyAll(context.Background(), s.snapshot(), value)
+}
+
+// NotifyAsync 在后台依次通知所有订阅者
+func (s *SubjectImpl[T]) NotifyAsync(ctx context.Context, value T) <-chan struct{} {
+	done := make(chan struct{})
+	subs := s.snapshot()
+	go func() {
+		defer close(done)
+		s.notifyAll(ctx, subs, value)
+	}()
+	return done
+}
+
+func (s *SubjectImpl[T]) notifyAll(ctx context.Context, subs []*subscription[T], value T) {
+	now := s.now()
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if sub.expired(now) {
+			sub.Unsubscribe()
+			continue
+		}
+		if sub.removed.Load() {
+			continue
+		}
+		sub.observer.Update(value)
+	}
+}
@@ -0,0 +1,101 @@
+package observer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	name string
+	log  *[]string
+}
+
+func (r *recorder) Update(msg string) {
+	*r.log = append(*r.log, r.name+":"+msg)
+}
+
+func TestSubject_PriorityAndUnsubscribe(t *testing.T) {
+	var log []string
+	subject := NewSubject[string]()
+	low := &recorder{name: "low", log: &log}
+	subject.Subscribe(low)
+	subject.Subscribe(&recorder{name: "high", log: &log}, WithPriority(10))
+	fn := subject.Subscribe(ObserverFunc[string](func(msg string) { log = append(log, "fn:"+msg) }))
+
+	subject.Notify("a")
+	subject.Unsubscribe(low)
+	fn.Unsubscribe()
+	subject.Notify("b")
+
+	want := "high:a,low:a,fn:a,high:b"
+	if got := strings.Join(log, ","); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestSubject_NotifyAsync(t *testing.T) {
+	subject := NewSubject[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []int
+	subject.Subscribe(ObserverFunc[int](func(n int) {
+		got = append(got, n)
+		cancel()
+	}), WithPriority(1))
+	subject.Subscribe(ObserverFunc[int](func(n int) {
+		got = append(got, -n)
+	}))
+
+	<-subject.NotifyAsync(ctx, 1)
+	if len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected notification to stop after cancel, got %v", got)
+	}
+}
+
+func TestSubject_Expiring(t *testing.T) {
+	clock := NewVirtualClock(time.Unix(0, 0))
+	subject := NewSubject[int](WithClock(clock))
+
+	var ttl, scoped int
+	subject.Subscribe(ObserverFunc[int](func(int) { ttl++ }), WithTTL(time.Minute))
+	ctx, cancel := context.WithCancel(context.Background())
+	subject.Subscribe(ObserverFunc[int](func(int) { scoped++ }), WithContext(ctx))
+
+	subject.Notify(1)
+	clock.Advance(time.Minute)
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for subject.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	subject.Notify(2)
+
+	if ttl != 1 || scoped != 1 || subject.Len() != 0 {
+		t.Fatalf("expected expired subscriptions to be removed, got ttl=%d scoped=%d len=%d", ttl, scoped, subject.Len())
+	}
+}
+
+func TestSubject_SubscribeWithCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	subject := NewSubject[int]()
+	for i := 0; i < 100; i++ {
+		// ctx 已结束，AfterFunc 会在另一个 goroutine 中立即取消订阅，由 go test -race 检查
+		sub := subject.Subscribe(ObserverFunc[int](func(int) {}), WithContext(ctx))
+		if i%2 == 0 {
+			sub.Unsubscribe()
+		}
+	}
+	// 操作符同样通过 WithContext 订阅上游
+	collect[int](Map(ctx, subject, func(n int) int { return n }))
+
+	deadline := time.Now().Add(time.Second)
+	for subject.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if subject.Len() != 0 {
+		t.Fatalf("expected subscriptions with a cancelled ctx to be removed, got %d", subject.Len())
+	}
+}
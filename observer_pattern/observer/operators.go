@@ -0,0 +1,238 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 以下操作符都会订阅 src 并返回一个新的 Subject，新 Subject 可以继续作为其他操作符的输入。
+// ctx 结束后操作符会取消对 src 的订阅并停止内部的定时器，不再产生新的通知。
+// 需要计时的操作符使用传入的 Clock，为 nil 时使用 SystemClock，测试时可以传入 VirtualClock；
+// 它们的间隔 d 必须大于 0，否则和 time.NewTicker 一样 panic。
+
+// operator 把 src 的通知交给 onValue 处理，ctx 结束时调用 onDone
+func operator[T, U any](ctx context.Context, src Subject[T], onValue func(out *SubjectImpl[U], value T), onDone func()) *SubjectImpl[U] {
+	out := NewSubject[U]()
+	if ctx.Err() != nil {
+		return out
+	}
+	src.Subscribe(ObserverFunc[T](func(value T) {
+		if ctx.Err() == nil {
+			onValue(out, value)
+		}
+	}), WithContext(ctx))
+	if onDone != nil {
+		context.AfterFunc(ctx, onDone)
+	}
+	return out
+}
+
+// mustPositive 检查计时类操作符的间隔，d <= 0 时定时器会在当前时刻反复触发
+func mustPositive(operator string, d time.Duration) {
+	if d <= 0 {
+		panic(fmt.Sprintf("observer: non-positive interval %v for %s", d, operator))
+	}
+}
+
+func clockOrDefault(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+// Map 把每个值通过 fn 转换后通知
+func Map[T, U any](ctx context.Context, src Subject[T], fn func(T) U) *SubjectImpl[U] {
+	return operator(ctx, src, func(out *SubjectImpl[U], value T) {
+		out.Notify(fn(value))
+	}, nil)
+}
+
+// Filter 只通知满足 predicate 的值
+func Filter[T any](ctx context.Context, src Subject[T], predicate func(T) bool) *SubjectImpl[T] {
+	return operator(ctx, src, func(out *SubjectImpl[T], value T) {
+		if predicate(value) {
+			out.Notify(value)
+		}
+	}, nil)
+}
+
+// DistinctUntilChanged 跳过与上一个值相同的值
+func DistinctUntilChanged[T comparable](ctx context.Context, src Subject[T]) *SubjectImpl[T] {
+	var lock sync.Mutex
+	var last T
+	seen := false
+	return operator(ctx, src, func(out *SubjectImpl[T], value T) {
+		lock.Lock()
+		changed := !seen || value != last
+		last, seen = value, true
+		lock.Unlock()
+		if changed {
+			out.Notify(value)
+		}
+	}, nil)
+}
+
+// Debounce 值停止变化 d 之后才通知最后一个值
+func Debounce[T any](ctx context.Context, src Subject[T], d time.Duration, clock Clock) *SubjectImpl[T] {
+	mustPositive("Debounce", d)
+	clock = clockOrDefault(clock)
+	var lock sync.Mutex
+	var timer Timer
+	var gen uint64
+
+	return operator(ctx, src, func(out *SubjectImpl[T], value T) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		gen++
+		current := gen
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = clock.AfterFunc(d, func() {
+			lock.Lock()
+			// 定时器触发时已经有更新的值，交给新的定时器处理
+			stale := current != gen
+			lock.Unlock()
+			if !stale && ctx.Err() == nil {
+				out.Notify(value)
+			}
+		})
+	}, func() {
+		lock.Lock()
+		defer lock.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+	})
+}
+
+// Throttle 通知一个值后，d 之内的其他值都被丢弃
+func Throttle[T any](ctx context.Context, src Subject[T], d time.Duration, clock Clock) *SubjectImpl[T] {
+	mustPositive("Throttle", d)
+	clock = clockOrDefault(clock)
+	var lock sync.Mutex
+	var next time.Time
+	return operator(ctx, src, func(out *SubjectImpl[T], value T) {
+		lock.Lock()
+		now := clock.Now()
+		allowed := !now.Before(next)
+		if allowed {
+			next = now.Add(d)
+		}
+		lock.Unlock()
+		if allowed {
+			out.Notify(value)
+		}
+	}, nil)
+}
+
+// BufferCount 每收集 n 个值通知一次
+func BufferCount[T any](ctx context.Context, src Subject[T], n int) *SubjectImpl[[]T] {
+	var lock sync.Mutex
+	var buf []T
+	return operator(ctx, src, func(out *SubjectImpl[[]T], value T) {
+		lock.Lock()
+		buf = append(buf, value)
+		if len(buf) < n {
+			lock.Unlock()
+			return
+		}
+		batch := buf
+		buf = nil
+		lock.Unlock()
+		out.Notify(batch)
+	}, nil)
+}
+
+// BufferTime 每隔 d 把这段时间内收集到的值一起通知，没有值时不通知
+func BufferTime[T any](ctx context.Context, src Subject[T], d time.Duration, clock Clock) *SubjectImpl[[]T] {
+	mustPositive("BufferTime", d)
+	clock = clockOrDefault(clock)
+	var lock sync.Mutex
+	var buf []T
+	var timer Timer
+
+	out := operator(ctx, src, func(_ *SubjectImpl[[]T], value T) {
+		lock.Lock()
+		buf = append(buf, value)
+		lock.Unlock()
+	}, func() {
+		lock.Lock()
+		defer lock.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+	})
+
+	var flush func()
+	flush = func() {
+		lock.Lock()
+		batch := buf
+		buf = nil
+		if ctx.Err() == nil {
+			timer = clock.AfterFunc(d, flush)
+		}
+		lock.Unlock()
+		if len(batch) > 0 && ctx.Err() == nil {
+			out.Notify(batch)
+		}
+	}
+	if ctx.Err() == nil {
+		lock.Lock()
+		timer = clock.AfterFunc(d, flush)
+		lock.Unlock()
+	}
+	return out
+}
+
+// Merge 把多个 Subject 的通知合并到一起
+func Merge[T any](ctx context.Context, srcs ...Subject[T]) *SubjectImpl[T] {
+	out := NewSubject[T]()
+	if ctx.Err() != nil {
+		return out
+	}
+	for _, src := range srcs {
+		src.Subscribe(ObserverFunc[T](func(value T) {
+			if ctx.Err() == nil {
+				out.Notify(value)
+			}
+		}), WithContext(ctx))
+	}
+	return out
+}
+
+// CombineLatest 所有 Subject 都至少通知过一次后，任意一个 Subject 通知时，
+// 按 srcs 的顺序通知每个 Subject 最新的值
+func CombineLatest[T any](ctx context.Context, srcs ...Subject[T]) *SubjectImpl[[]T] {
+	out := NewSubject[[]T]()
+	if ctx.Err() != nil {
+		return out
+	}
+
+	var lock sync.Mutex
+	latest := make([]T, len(srcs))
+	seen := make([]bool, len(srcs))
+	remaining := len(srcs)
+	for i, src := range srcs {
+		i := i
+		src.Subscribe(ObserverFunc[T](func(value T) {
+			lock.Lock()
+			latest[i] = value
+			if !seen[i] {
+				seen[i] = true
+				remaining--
+			}
+			ready := remaining == 0
+			values := append([]T(nil), latest...)
+			lock.Unlock()
+			if ready && ctx.Err() == nil {
+				out.Notify(values)
+			}
+		}), WithContext(ctx))
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// collect 记录 subject 的所有通知
+func collect[T any](subject Subject[T]) *[]T {
+	var got []T
+	subject.Subscribe(ObserverFunc[T](func(value T) { got = append(got, value) }))
+	return &got
+}
+
+func assertValues[T any](t *testing.T, got []T, want string) {
+	t.Helper()
+	if s := fmt.Sprint(got); s != want {
+		t.Fatalf("expected %s, got %s", want, s)
+	}
+}
+
+func TestOperators_MapFilterDistinct(t *testing.T) {
+	ctx := context.Background()
+	src := NewSubject[int]()
+	even := Filter(ctx, src, func(n int) bool { return n%2 == 0 })
+	half := DistinctUntilChanged(ctx, Map(ctx, even, func(n int) int { return n / 4 }))
+	got := collect[int](half)
+
+	for _, n := range []int{1, 2, 4, 6, 8, 9, 12} {
+		src.Notify(n)
+	}
+	assertValues(t, *got, "[0 1 2 3]")
+}
+
+func TestOperators_DebounceAndThrottle(t *testing.T) {
+	ctx := context.Background()
+	clock := NewVirtualClock(time.Unix(0, 0))
+	src := NewSubject[string]()
+	debounced := collect[string](Debounce(ctx, src, 100*time.Millisecond, clock))
+	throttled := collect[string](Throttle(ctx, src, 100*time.Millisecond, clock))
+
+	src.Notify("a")
+	clock.Advance(50 * time.Millisecond)
+	src.Notify("b")
+	clock.Advance(50 * time.Millisecond)
+	src.Notify("c")
+	clock.Advance(150 * time.Millisecond)
+	src.Notify("d")
+	clock.Advance(100 * time.Millisecond)
+
+	assertValues(t, *debounced, "[c d]")
+	assertValues(t, *throttled, "[a c d]")
+}
+
+func TestOperators_Buffer(t *testing.T) {
+	ctx := context.Background()
+	clock := NewVirtualClock(time.Unix(0, 0))
+	src := NewSubject[int]()
+	byCount := collect[[]int](BufferCount(ctx, src, 2))
+	byTime := collect[[]int](BufferTime(ctx, src, time.Second, clock))
+
+	src.Notify(1)
+	src.Notify(2)
+	src.Notify(3)
+	clock.Advance(time.Second)
+	clock.Advance(time.Second)
+	src.Notify(4)
+	clock.Advance(time.Second)
+
+	assertValues(t, *byCount, "[[1 2] [3 4]]")
+	assertValues(t, *byTime, "[[1 2 3] [4]]")
+}
+
+func TestOperators_MergeAndCombineLatest(t *testing.T) {
+	ctx := context.Background()
+	a, b := NewSubject[int](), NewSubject[int]()
+	merged := collect[int](Merge[int](ctx, a, b))
+	combined := collect[[]int](CombineLatest[int](ctx, a, b))
+
+	a.Notify(1)
+	a.Notify(2)
+	b.Notify(10)
+	a.Notify(3)
+
+	assertValues(t, *merged, "[1 2 10 3]")
+	assertValues(t, *combined, "[[2 10] [3 10]]")
+}
+
+func TestOperators_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := NewVirtualClock(time.Unix(0, 0))
+	src := NewSubject[int]()
+	debounced := collect[int](Debounce(ctx, src, time.Second, clock))
+	mapped := collect[int](Map(ctx, src, func(n int) int { return n }))
+
+	src.Notify(1)
+	cancel()
+	clock.Advance(time.Second)
+	src.Notify(2)
+
+	assertValues(t, *debounced, "[]")
+	assertValues(t, *mapped, "[1]")
+	deadline := time.Now().Add(time.Second)
+	for src.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if src.Len() != 0 {
+		t.Fatalf("expected operators to unsubscribe from src after cancel")
+	}
+}
+
+func TestOperators_NonPositiveInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		d    time.Duration
+		op   func(ctx context.Context, src Subject[int], d time.Duration, clock Clock)
+	}{
+		{"Debounce", 0, func(ctx context.Context, src Subject[int], d time.Duration, clock Clock) {
+			Debounce(ctx, src, d, clock)
+		}},
+		{"Throttle", -time.Second, func(ctx context.Context, src Subject[int], d time.Duration, clock Clock) {
+			Throttle(ctx, src, d, clock)
+		}},
+		{"BufferTime", 0, func(ctx context.Context, src Subject[int], d time.Duration, clock Clock) {
+			BufferTime(ctx, src, d, clock)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected %s to panic with interval %v", tt.name, tt.d)
+				}
+			}()
+			// 不检查时 BufferTime 会让 Advance 在同一时刻无限循环
+			clock := NewVirtualClock(time.Time{})
+			tt.op(context.Background(), NewSubject[int](), tt.d, clock)
+			clock.Advance(time.Second)
+		})
+	}
+}